@@ -10,8 +10,14 @@ go get -u github.com/kardianos/govendor
 echo pulling in deps with govendor
 govendor sync
 
+# same version deploy.sh publishes the builds under. gcm self-update checks it.
+VERSION=0.0.$TRAVIS_BUILD_NUMBER
+COMMIT=${TRAVIS_COMMIT:-$(git rev-parse HEAD)}
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+LDFLAGS="-X github.com/gocms-io/gcm/config.GCM_VERSION=$VERSION -X github.com/gocms-io/gcm/config.GCM_COMMIT=$COMMIT -X github.com/gocms-io/gcm/config.GCM_BUILD_DATE=$BUILD_DATE"
+
 function buildArch() {
-    GOOS=$1 GOARCH=$2 go build -o bin/$TRAVIS_BRANCH/$3/$4
+    GOOS=$1 GOARCH=$2 go build -ldflags "$LDFLAGS" -o bin/$TRAVIS_BRANCH/$3/$4
     pushd bin/$TRAVIS_BRANCH/$3
     zip -r gocms.zip *
     popd
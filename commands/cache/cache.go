@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/urfave/cli"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const flag_older_than = "older-than"
+
+var CMD_CACHE = cli.Command{
+	Name:  "cache",
+	Usage: "Manage the cache of downloaded gocms releases and plugins. Set GCM_CACHE_DIR to move it.",
+	Subcommands: []cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List cached downloads, most recently used first.",
+			Action: cmd_cache_list,
+		},
+		{
+			Name:   "clean",
+			Usage:  "Remove all cached downloads.",
+			Action: cmd_cache_clean,
+		},
+		{
+			Name:   "prune",
+			Usage:  "Remove cached downloads that haven't been used for a while.",
+			Action: cmd_cache_prune,
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  flag_older_than,
+					Value: 30 * 24 * time.Hour,
+					Usage: "Remove downloads last used longer ago than this.",
+				},
+			},
+		},
+	},
+}
+
+func cmd_cache_list(c *cli.Context) error {
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	entries := cache.SortedEntries()
+	utility.SetResult("directory", cache.Dir)
+	utility.SetResult("entries", entries)
+
+	if len(entries) == 0 {
+		fmt.Printf("Nothing cached in %v\n", cache.Dir)
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tCHECKSUM\tSIZE\tLAST USED")
+	for _, entry := range entries {
+		total += entry.Size
+		lastUsed := "never"
+		if !entry.LastUsed.IsZero() {
+			lastUsed = entry.LastUsed.Format("2006-01-02 15:04")
+		}
+		checksum := entry.Checksum
+		if len(checksum) > 12 {
+			checksum = checksum[:12]
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", entry.Url, checksum, formatSize(entry.Size), lastUsed)
+	}
+	w.Flush()
+	fmt.Printf("%v downloads, %v in %v\n", len(entries), formatSize(total), cache.Dir)
+
+	return nil
+}
+
+func cmd_cache_clean(c *cli.Context) error {
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	removed, err := cache.Remove(func(entry *utility.CacheEntry) bool {
+		return true
+	})
+	if err != nil {
+		fmt.Printf("Error cleaning download cache: %v\n", err.Error())
+		return err
+	}
+
+	utility.SetResult("removed", len(removed))
+	fmt.Printf("Removed %v cached downloads.\n", len(removed))
+	return nil
+}
+
+func cmd_cache_prune(c *cli.Context) error {
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-c.Duration(flag_older_than))
+	removed, err := cache.Remove(func(entry *utility.CacheEntry) bool {
+		lastUsed := entry.LastUsed
+		if lastUsed.IsZero() {
+			lastUsed = entry.Downloaded
+		}
+		return lastUsed.Before(cutoff)
+	})
+	if err != nil {
+		fmt.Printf("Error pruning download cache: %v\n", err.Error())
+		return err
+	}
+
+	for _, entry := range removed {
+		fmt.Printf("Removed %v\n", entry.Url)
+	}
+	utility.SetResult("removed", len(removed))
+	fmt.Printf("Removed %v cached downloads not used since %v.\n", len(removed), cutoff.Format("2006-01-02 15:04"))
+	return nil
+}
+
+func openCache() (*utility.DownloadCache, error) {
+	cache, err := utility.OpenDownloadCache()
+	if err != nil {
+		fmt.Printf("Error opening download cache: %v\n", err.Error())
+		return nil, err
+	}
+	return cache, nil
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%vB", size)
+}
@@ -0,0 +1,115 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const flag_url = "url"
+const flag_health_url = "healthUrl"
+const flag_wait = "wait"
+
+var CMD_CHECK = cli.Command{
+	Name:      "check",
+	Usage:     "Check that an installed gocms is up and that every plugin route responds.",
+	ArgsUsage: "<directory>",
+	Action:    cmd_check,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  flag_url,
+			Usage: "Url of the running gocms. Defaults to localhost and the port from the installation's .env file.",
+		},
+		cli.StringFlag{
+			Name:  flag_health_url,
+			Usage: "Url polled to decide gocms is ready. Defaults to the gocms url.",
+		},
+		cli.DurationFlag{
+			Name:  flag_wait,
+			Value: 5 * time.Second,
+			Usage: "How long to wait for gocms to become ready.",
+		},
+	},
+}
+
+func cmd_check(c *cli.Context) error {
+
+	args, err := sites.Args(c, 0)
+	if err != nil {
+		return err
+	}
+	if !args.Present() {
+		errStr := "An install directory must be specified."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	installDir := filepath.Clean(args.First())
+
+	// verify this is a gocms dir
+	if _, err := os.Stat(filepath.Join(utility.ActiveInstallDir(installDir), config.BINARY_FILE)); os.IsNotExist(err) {
+		errStr := "The provided directory doesn't appear to be an active GoCMS installation."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	goCMSUrl := c.String(flag_url)
+	if goCMSUrl == "" {
+		goCMSUrl = utility.GoCMSUrl(installDir)
+	}
+	healthUrl := c.String(flag_health_url)
+	if healthUrl == "" {
+		healthUrl = goCMSUrl
+	}
+
+	// wait for gocms
+	start := time.Now()
+	err = utility.WaitForReady(context.Background(), healthUrl, c.Duration(flag_wait))
+	if err != nil {
+		fmt.Printf("GoCMS is not ready: %v\n", err.Error())
+		return err
+	}
+	utility.PrintReadyBanner(goCMSUrl, time.Since(start))
+
+	// check the routes of every installed plugin
+	pluginsDir := filepath.Join(utility.ActiveInstallDir(installDir), config.CONTENT_DIR, config.PLUGINS_DIR)
+	pluginDirs, err := ioutil.ReadDir(pluginsDir)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error reading plugins in %v: %v\n", pluginsDir, err.Error())
+		return err
+	}
+
+	failed := 0
+	for _, pluginDir := range pluginDirs {
+		manifestPath := filepath.Join(pluginsDir, pluginDir.Name(), config.PLUGIN_MANIFEST)
+		if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+			continue
+		}
+
+		manifest, err := utility.ParseManifest(manifestPath)
+		if err != nil {
+			fmt.Printf("Error parsing manifest file %v: %v\n", manifestPath, err.Error())
+			failed++
+			continue
+		}
+
+		checks := utility.CheckRoutes(context.Background(), goCMSUrl, manifest.Services.Routes)
+		failed += utility.PrintRouteChecks(manifest.Id, checks)
+	}
+
+	if failed > 0 {
+		errStr := fmt.Sprintf("%v plugin route checks failed.", failed)
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"fmt"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// applyBuildFlags layers build flags from the command line over the build section of gcm.yaml.
+func (pctx *pluginContext) applyBuildFlags(c *cli.Context) error {
+
+	// tags
+	if c.StringSlice(flag_build_tags) != nil {
+		pctx.build.Tags = append(pctx.build.Tags, c.StringSlice(flag_build_tags)...)
+	}
+
+	// ldflags
+	if c.String(flag_build_ldflags) != "" {
+		pctx.build.LdFlags = strings.TrimSpace(pctx.build.LdFlags + " " + c.String(flag_build_ldflags))
+	}
+
+	// version and build injection
+	if c.String(flag_build_version_var) != "" {
+		pctx.build.VersionVar = c.String(flag_build_version_var)
+	}
+	if c.String(flag_build_build_var) != "" {
+		pctx.build.BuildVar = c.String(flag_build_build_var)
+	}
+
+	// race and trimpath
+	if c.Bool(flag_build_race) {
+		pctx.build.Race = true
+	}
+	if c.Bool(flag_build_trimpath) {
+		pctx.build.TrimPath = true
+	}
+
+	// cgo
+	if c.String(flag_build_cgo) != "" {
+		cgo, err := strconv.ParseBool(c.String(flag_build_cgo))
+		if err != nil {
+			errStr := fmt.Sprintf("Invalid value for --%v: %v. Use 0 or 1 (or true or false).", flag_build_cgo, c.String(flag_build_cgo))
+			fmt.Println(errStr)
+			return errors.New(errStr)
+		}
+		pctx.build.Cgo = &cgo
+	}
+
+	// the race detector is built on cgo
+	if pctx.build.Race && pctx.build.Cgo != nil && !*pctx.build.Cgo {
+		errStr := fmt.Sprintf("--%v needs cgo and can't be combined with --%v 0.", flag_build_race, flag_build_cgo)
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+
+	// targets
+	if c.StringSlice(flag_build_target) != nil {
+		pctx.build.Targets = append(pctx.build.Targets, c.StringSlice(flag_build_target)...)
+	}
+	targets, err := parseBuildTargets(pctx.build.Targets)
+	if err != nil {
+		return err
+	}
+	pctx.targets = targets
+
+	// env
+	for _, env := range c.StringSlice(flag_build_env) {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			errStr := fmt.Sprintf("Invalid value for --%v: %v. Use KEY=VALUE.", flag_build_env, env)
+			fmt.Println(errStr)
+			return errors.New(errStr)
+		}
+		if pctx.build.Env == nil {
+			pctx.build.Env = make(map[string]string)
+		}
+		pctx.build.Env[kv[0]] = kv[1]
+	}
+
+	// injected values
+	if pctx.build.VersionVar != "" {
+		err = checkLdFlagValue(pctx.build.VersionVar, pctx.manifest.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkLdFlagValue rejects values ldFlagX can't quote. go doesn't unescape -ldflags so a value can't hold both
+// kinds of quotes.
+func checkLdFlagValue(variable string, value string) error {
+	if strings.Contains(value, "'") && strings.Contains(value, `"`) {
+		errStr := fmt.Sprintf("Can't inject %v into %v with -ldflags -X. It contains both ' and \".", value, variable)
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// buildArgs returns the arguments passed to go to build the plugin binary into binPath.
+func (pctx *pluginContext) buildArgs(binPath string, race bool) []string {
+	args := []string{"build", "-o", binPath}
+
+	if len(pctx.build.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(pctx.build.Tags, ","))
+	}
+
+	ldFlags := pctx.build.LdFlags
+	if pctx.build.VersionVar != "" {
+		ldFlags = strings.TrimSpace(ldFlags + " " + ldFlagX(pctx.build.VersionVar, pctx.manifest.Version))
+	}
+	if pctx.build.BuildVar != "" {
+		ldFlags = strings.TrimSpace(ldFlags + " " + ldFlagX(pctx.build.BuildVar, fmt.Sprintf("%v", pctx.manifest.Build)))
+	}
+	if ldFlags != "" {
+		args = append(args, "-ldflags", ldFlags)
+	}
+
+	if race {
+		args = append(args, "-race")
+	}
+
+	if pctx.build.TrimPath {
+		args = append(args, "-trimpath")
+	}
+
+	return append(args, filepath.Join(pctx.srcDir, pctx.buildEntry))
+}
+
+// ldFlagX sets variable to value with -X. The assignment is quoted so values with spaces stay one argument. See
+// checkLdFlagValue for what can't be quoted.
+func ldFlagX(variable string, value string) string {
+	if strings.Contains(value, "'") {
+		return fmt.Sprintf(`-X "%v=%v"`, variable, value)
+	}
+	return fmt.Sprintf("-X '%v=%v'", variable, value)
+}
+
+// buildEnv returns the environment for go build. Values set for the plugin win over the inherited environment.
+func (pctx *pluginContext) buildEnv() []string {
+	env := os.Environ()
+
+	if pctx.build.Cgo != nil {
+		cgoEnabled := "0"
+		if *pctx.build.Cgo {
+			cgoEnabled = "1"
+		}
+		env = append(env, "CGO_ENABLED="+cgoEnabled)
+	}
+
+	for key, value := range pctx.build.Env {
+		env = append(env, fmt.Sprintf("%v=%v", key, value))
+	}
+
+	return env
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/models"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+)
+
+const hook_pre_build = "preBuild"
+const hook_post_build = "postBuild"
+const hook_pre_copy = "preCopy"
+const hook_post_copy = "postCopy"
+const hook_pre_run = "preRun"
+
+// runHooks runs each hook for a stage in order. The first failing hook aborts the stage unless it is marked continueOnError.
+func (pctx *pluginContext) runHooks(ctx context.Context, stage string, hooks []*models.PluginHook) error {
+	for i, hook := range hooks {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		hookName := hook.Name
+		if hookName == "" {
+			hookName = fmt.Sprintf("#%v", i+1)
+		}
+
+		fmt.Printf("Running %v hook '%v'\n", stage, hookName)
+		err := utility.RunHook(ctx, hook, pctx.srcDir, pctx.verbose, pctx.buildStdout, pctx.buildStderr)
+		if err != nil {
+			errStr := fmt.Sprintf("%v hook '%v' failed: %v", stage, hookName, err.Error())
+			fmt.Println(errStr)
+			if hook.ContinueOnError {
+				continue
+			}
+			return errors.New(errStr)
+		}
+	}
+
+	return nil
+}
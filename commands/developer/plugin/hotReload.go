@@ -0,0 +1,34 @@
+package plugin
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/utility"
+	"runtime"
+)
+
+// canHotReload reports whether a rebuild can be applied by restarting only the plugin process.
+// A changed manifest can change routes and interface files which gocms only reads on start, and
+// windows can't replace the binary of a running plugin.
+func (pctx *pluginContext) canHotReload(manifestChanged bool) bool {
+	return pctx.hotReload && !manifestChanged && pctx.goCMSRunning() && runtime.GOOS != "windows"
+}
+
+// reloadPlugin asks the running gocms to restart the plugin from the binary that is now in place.
+func (pctx *pluginContext) reloadPlugin() error {
+	fmt.Printf("Reloading plugin %v\n", pctx.manifest.Id)
+
+	err := utility.ReloadPlugin(pctx.goCMSUrl, pctx.manifest.Id)
+	if err == utility.ErrReloadNotSupported {
+		// don't ask again on every rebuild
+		fmt.Println("This gocms can't reload plugins. Turning --hot off.")
+		pctx.hotReload = false
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Plugin %v reloaded\n", pctx.manifest.Id)
+	pctx.waitForReady()
+	return nil
+}
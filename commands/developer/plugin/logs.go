@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"os"
+	"strings"
+)
+
+// setupLogs tags gcm, build and gocms output so they can be told apart while watching or running.
+// The returned func restores stdout and writes out anything still buffered.
+func (pctx *pluginContext) setupLogs(c *cli.Context) (func(), error) {
+	logs := utility.NewLogMux(os.Stdout)
+	logs.Timestamps = c.Bool(flag_timestamps)
+
+	switch c.String(flag_color) {
+	case "", "auto":
+	case "always":
+		logs.Color = true
+	case "never":
+		logs.Color = false
+	default:
+		errStr := fmt.Sprintf("Invalid value for --%v: %v. Use auto, always or never.", flag_color, c.String(flag_color))
+		fmt.Println(errStr)
+		return nil, errors.New(errStr)
+	}
+
+	level, err := utility.ParseLogLevel(c.String(flag_log_level))
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil, err
+	}
+	logs.Level = level
+
+	var logFile *os.File
+	if c.String(flag_log_file) != "" {
+		logFile, err = os.OpenFile(c.String(flag_log_file), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Printf("Error opening log file: %v\n", err.Error())
+			return nil, err
+		}
+		logs.Tee(logFile)
+	}
+
+	// everything gcm prints, including from the utility package, becomes [gcm]
+	gcmStream := logs.Stream("gcm", utility.LogInfo)
+	restoreStdout, err := utility.CaptureStdout(gcmStream)
+	if err != nil {
+		if logFile != nil {
+			logFile.Close()
+		}
+		return nil, err
+	}
+
+	buildStream := logs.Stream("build", utility.LogInfo)
+	pctx.buildStdout = buildStream
+	pctx.buildStderr = buildStream
+	pctx.logs = logs
+
+	return func() {
+		restoreStdout()
+		gcmStream.Close()
+		buildStream.Close()
+		if logFile != nil {
+			logFile.Close()
+		}
+	}, nil
+}
+
+// goCMSLogStream returns a stream for gocms output that passes lines gocms prefixes with the plugin id on as [plugin:<id>].
+func (pctx *pluginContext) goCMSLogStream(tag string, level utility.LogLevel) *utility.LogStream {
+	stream := pctx.logs.Stream(tag, level)
+
+	pluginId := strings.ToLower(pctx.manifest.Id)
+	pluginTag := "plugin:" + pctx.manifest.Id
+	stream.Route = func(line string) string {
+		start := strings.ToLower(strings.TrimSpace(line))
+		if strings.HasPrefix(start, "["+pluginId+"]") || strings.HasPrefix(start, pluginId+":") {
+			return pluginTag
+		}
+		return tag
+	}
+
+	return stream
+}
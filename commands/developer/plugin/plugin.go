@@ -1,12 +1,15 @@
 package plugin
 
 import (
+	"context"
 	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
 	"github.com/gocms-io/gcm/config"
 	"github.com/gocms-io/gcm/models"
 	"github.com/gocms-io/gcm/utility"
 	"github.com/gocms-io/gocms/utility/errors"
 	"github.com/urfave/cli"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
@@ -15,6 +18,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -33,26 +37,65 @@ const flag_gocms_dev_mode = "gocms"
 const flag_gocms_dev_mode_short = "g"
 const flag_ignore_files = "ignore"
 const flag_ignore_files_short = "i"
+const flag_build_tags = "tags"
+const flag_build_ldflags = "ldflags"
+const flag_build_version_var = "versionVar"
+const flag_build_build_var = "buildVar"
+const flag_build_race = "race"
+const flag_build_trimpath = "trimpath"
+const flag_build_cgo = "cgo"
+const flag_build_env = "buildEnv"
+const flag_build_target = "target"
+const flag_build_target_short = "t"
+const flag_debounce = "debounce"
+const flag_hot_reload = "hot"
+const flag_gocms_url = "url"
+const flag_health_url = "healthUrl"
+const flag_ready_timeout = "readyTimeout"
+const flag_color = "color"
+const flag_timestamps = "timestamps"
+const flag_log_level = "logLevel"
+const flag_log_file = "logFile"
+const flag_package = "package"
 
 type pluginContext struct {
-	hardCopy                   bool
-	watch                      bool
-	buildEntry                 string
-	filesToCopy                []string
-	run                        bool
-	devMode                    bool
-	pluginPath                 string
-	srcDir                     string
-	destDir                    string
-	verbose                    bool
-	manifest                   *models.PluginManifest
-	goCMSDoneChan              chan bool
-	watcherDoneChan            chan bool
-	systemDoneChan             chan bool
-	ignorePath                 []string
-	goBuildExec                *exec.Cmd
-	watcherFileContext         *utility.WatchFileContext
-	skipRunDueToFailedComplile bool
+	hardCopy           bool
+	watch              bool
+	buildEntry         string
+	filesToCopy        []string
+	run                bool
+	devMode            bool
+	pluginPath         string
+	srcDir             string
+	destDir            string
+	verbose            bool
+	manifest           *models.PluginManifest
+	watcherDoneChan    chan bool
+	systemDoneChan     chan bool
+	ignorePath         []string
+	ignoreRegex        []*regexp.Regexp
+	goBuildExec        *exec.Cmd
+	watcherFileContext *utility.WatchFileContext
+	goCMS              *utility.Supervisor
+	goCMSLock          sync.Mutex
+	hooks              models.PluginHooks
+	build              models.PluginBuild
+	targets            []*buildTarget
+	debounce           time.Duration
+	buildScheduler     *utility.BuildScheduler
+	cliContext         *cli.Context
+	manifestChecksum   string
+	binPath            string
+	stagedBinPath      string
+	hotReload          bool
+	goCMSUrl           string
+	healthUrl          string
+	readyTimeout       time.Duration
+	readyCancel        context.CancelFunc
+	logs               *utility.LogMux
+	buildStdout        io.Writer
+	buildStderr        io.Writer
+	packagePath        string
 }
 
 var CMD_PLUGIN = cli.Command{
@@ -69,6 +112,46 @@ var CMD_PLUGIN = cli.Command{
 			Name:  flag_watch + ", " + flag_watch_short,
 			Usage: "Watch for file changes in source and copy to destination on change.",
 		},
+		cli.DurationFlag{
+			Name:  flag_debounce,
+			Value: 500 * time.Millisecond,
+			Usage: "How long to wait for changes to settle before rebuilding while watching.",
+		},
+		cli.BoolFlag{
+			Name:  flag_hot_reload,
+			Usage: "Experimental. While watching with --run, ask gocms to restart only the plugin after a rebuild through POST /api/plugins/<id>/reload, which released versions of gocms don't serve yet. Without it gocms is restarted.",
+		},
+		cli.StringFlag{
+			Name:  flag_gocms_url,
+			Usage: "Url of the running gocms. Defaults to localhost and the port from the installation's .env file.",
+		},
+		cli.StringFlag{
+			Name:  flag_health_url,
+			Usage: "Url polled after starting gocms to decide it is ready. Defaults to the gocms url.",
+		},
+		cli.DurationFlag{
+			Name:  flag_ready_timeout,
+			Value: 30 * time.Second,
+			Usage: "How long to wait for gocms to become ready after starting it.",
+		},
+		cli.StringFlag{
+			Name:  flag_color,
+			Value: "auto",
+			Usage: "Color log prefixes while watching or running: auto, always or never.",
+		},
+		cli.BoolFlag{
+			Name:  flag_timestamps,
+			Usage: "Prefix log lines with the time while watching or running.",
+		},
+		cli.StringFlag{
+			Name:  flag_log_level,
+			Value: "info",
+			Usage: "Hide log lines below this level while watching or running: debug, info, warn or error.",
+		},
+		cli.StringFlag{
+			Name:  flag_log_file,
+			Usage: "Also write all output, whatever its level, to this file while watching or running.",
+		},
 		cli.StringFlag{
 			Name:  flag_entry + ", " + flag_entry_short,
 			Usage: "Build the plugin using the following entry point. Defaults to 'main.go'.",
@@ -89,6 +172,46 @@ var CMD_PLUGIN = cli.Command{
 			Name:  flag_ignore_files + ", " + flag_ignore_files_short,
 			Usage: "Files to ignore while watching. Multiple ignore flags can be given to ignore multiple files. Ignore files are regex capable. ex: .git*",
 		},
+		cli.StringSliceFlag{
+			Name:  flag_build_tags,
+			Usage: "Build tag to pass to go build. Accepts multiple instances of the flag.",
+		},
+		cli.StringFlag{
+			Name:  flag_build_ldflags,
+			Usage: "Flags to pass to go build with -ldflags.",
+		},
+		cli.StringFlag{
+			Name:  flag_build_version_var,
+			Usage: "Package variable to set to the manifest version at build time. ex: main.version",
+		},
+		cli.StringFlag{
+			Name:  flag_build_build_var,
+			Usage: "Package variable to set to the manifest build number at build time. ex: main.build",
+		},
+		cli.BoolFlag{
+			Name:  flag_build_race,
+			Usage: "Build the plugin with the race detector enabled.",
+		},
+		cli.BoolFlag{
+			Name:  flag_build_trimpath,
+			Usage: "Build the plugin with -trimpath.",
+		},
+		cli.StringFlag{
+			Name:  flag_build_cgo,
+			Usage: "Set CGO_ENABLED for the plugin build. Accepts 0 or 1 (or true or false).",
+		},
+		cli.StringSliceFlag{
+			Name:  flag_build_env,
+			Usage: "Environment variable to set for the plugin build as KEY=VALUE. Accepts multiple instances of the flag.",
+		},
+		cli.StringSliceFlag{
+			Name:  flag_build_target + ", " + flag_build_target_short,
+			Usage: "Also cross compile the plugin for these GOOS/GOARCH targets into per-target directories. ex: linux/amd64,linux/arm,windows/amd64",
+		},
+		cli.StringFlag{
+			Name:  flag_package,
+			Usage: "Package the built plugin into this archive for 'gcm plugin install'. The format follows the extension: .zip, .tar, .tar.gz or .tar.zst",
+		},
 	},
 }
 
@@ -97,51 +220,67 @@ func cmd_copy_plugin(c *cli.Context) error {
 	// get command context from cli
 	pctx, err := buildContextFromFlags(c)
 	if err != nil {
-		return err
+		return utility.NewError(utility.KindUsage, err)
 	}
 
-	// build binary
-	err = pctx.getBinaryBuildCommand()
-	if err != nil {
-		return err
+	// tag output from gcm, the build and gocms while they are interleaved. json output is already structured
+	if (pctx.run || pctx.watch) && !utility.JSONOutput() {
+		restoreLogs, err := pctx.setupLogs(c)
+		if err != nil {
+			return utility.NewError(utility.KindUsage, err)
+		}
+		defer restoreLogs()
 	}
 
 	fmt.Printf("Starting Build and Copy - %v\n", time.Now().Format("03:04:05"))
 
-	// to try run go generate or fail nice and continue
-	err = pctx.goGenerate()
-	if err != nil {
-		fmt.Println("Error running go generate. Continue anyway.")
-	}
-
-	// build binary
-	err = pctx.runBinaryBuildCommand()
+	// build binary and copy files
+	err = pctx.buildAndCopy(context.Background())
 	if err != nil {
 		return err
 	}
 
-	// copy files
-	pctx.copyPluginFiles()
-
 	fmt.Printf("Build and Copy Complete - %v\n", time.Now().Format("03:04:05"))
 
+	// package plugin
+	if pctx.packagePath != "" {
+		err = pctx.packagePlugin()
+		if err != nil {
+			return err
+		}
+	}
+
 	if pctx.run || pctx.watch {
 		pctx.systemDoneChan = make(chan bool)
+		if pctx.watch {
+			pctx.buildScheduler = utility.NewBuildScheduler(pctx.debounce, pctx.rebuild)
+		}
+
+		// setup gracful close to prevent port leaks and half finished builds
+		c := make(chan os.Signal, 2)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-c
+			fmt.Printf("Quiting...\n")
+			if pctx.buildScheduler != nil {
+				pctx.buildScheduler.Stop()
+			}
+			pctx.stopGoCMS()
+			close(pctx.systemDoneChan)
+		}()
+
 		if pctx.run {
 
-			// setup gracful close to prevent port leaks
-			c := make(chan os.Signal, 2)
-			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-			go func() {
-				<-c
-				fmt.Printf("Quiting...\n")
-				close(pctx.goCMSDoneChan)
-				time.Sleep(time.Second * 2)
-				close(pctx.systemDoneChan)
-			}()
-
-			pctx.goCMSDoneChan = make(chan bool)
-			pctx.runGoCMS()
+			// run pre run hooks
+			err = pctx.runHooks(context.Background(), hook_pre_run, pctx.hooks.PreRun)
+			if err != nil {
+				return err
+			}
+
+			err = pctx.runGoCMS()
+			if err != nil {
+				return err
+			}
 		}
 
 		// if watch create watcher context and run
@@ -159,99 +298,125 @@ func cmd_copy_plugin(c *cli.Context) error {
 	return nil
 }
 
-func (pctx *pluginContext) startFileWatcher() {
-
-	pctx.watcherFileContext = &utility.WatchFileContext{
-		Verbose:          pctx.verbose,
-		SourceBase:       pctx.srcDir,
-		IgnorePaths:      pctx.ignorePath,
-		DoneChan:         pctx.watcherDoneChan,
-		ChangeTimeoutMap: make(map[string]time.Time),
-		Chmod:            utility.IgnoreDestination,
-		Removed:          pctx.onFileChangeHandler,
-		Create:           pctx.onFileChangeHandler,
-		Rename:           utility.IgnoreDestination,
-		Write:            pctx.onFileChangeHandler,
-	}
-
-	if pctx.devMode {
-		fmt.Printf("Dev mode enabled. Waiting 5 seconds before watching files for change.\n")
-		time.Sleep(time.Second * 5)
+// packagePlugin archives the installed plugin directory so it can be shipped as is.
+func (pctx *pluginContext) packagePlugin() error {
+	fmt.Printf("Packaging %v into %v\n", pctx.manifest.Id, pctx.packagePath)
+	err := utility.CreateArchive(pctx.packagePath, pctx.pluginPath)
+	if err != nil {
+		fmt.Printf("Error packaging plugin: %v\n", err.Error())
+		return err
 	}
-	go pctx.watcherFileContext.Watch()
+	utility.SetResult("package", pctx.packagePath)
+	return nil
 }
 
-func (pctx *pluginContext) onFileChangeHandler(c *utility.WatchFileContext, eventPath string) {
+// buildAndCopy runs one full build cycle along with the hooks around each step.
+func (pctx *pluginContext) buildAndCopy(ctx context.Context) error {
 
-	// ignore changes to "."
-	if eventPath == "." || eventPath == "/" || eventPath == "./" || eventPath == "" {
-		return
+	// build binary
+	err := pctx.buildBinary(ctx)
+	if err != nil {
+		return utility.NewError(utility.KindBuild, err)
 	}
 
-	// ignore paths as specified
-	for _, ignorePath := range c.IgnorePaths {
-		ignorePathRegex, _ := regexp.Compile(ignorePath)
-		if ignorePathRegex.MatchString(filepath.Clean(eventPath)) {
-			return
-		}
+	// copy files
+	err = pctx.copyFiles(ctx)
+	if err != nil {
+		return utility.NewError(utility.KindCopy, err)
 	}
 
-	// check if file change is to soon and skip if needed
-	currentTime := time.Now()
-	fileChangedLastTime := c.ChangeTimeoutMap[eventPath]
-	if currentTime.Sub(fileChangedLastTime) < (time.Second * 5) {
-		return
+	// move binary into place
+	_, err = pctx.installBinary()
+	if err != nil {
+		return utility.NewError(utility.KindCopy, err)
 	}
 
-	// set file change time
-	c.ChangeTimeoutMap[eventPath] = currentTime
+	return nil
+}
 
-	fmt.Printf("Changes Dectected in '%v'\n", eventPath)
+// buildBinary builds the plugin binary to its staging path and any cross compile targets.
+func (pctx *pluginContext) buildBinary(ctx context.Context) error {
 
-	if pctx.run {
-		if !pctx.skipRunDueToFailedComplile {
-			fmt.Printf("Stopping GoCMS\n")
-			close(pctx.goCMSDoneChan)
-		}
+	// run pre build hooks
+	err := pctx.runHooks(ctx, hook_pre_build, pctx.hooks.PreBuild)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Start Rebuild & Copy - %v\n", time.Now().Format("03:04:05"))
-
 	// get binary build command
-	err := pctx.getBinaryBuildCommand()
+	err = pctx.getBinaryBuildCommand(ctx)
 	if err != nil {
-		fmt.Printf("Error getting new binary build command: %v\n", err.Error())
+		fmt.Printf("Error getting binary build command: %v\n", err.Error())
+		return err
 	}
 
-	// run go generate
-	err = pctx.goGenerate()
+	// to try run go generate or fail nice and continue
+	err = pctx.goGenerate(ctx)
 	if err != nil {
-		fmt.Printf("Error running go generate: %v\n", err.Error())
+		fmt.Println("Error running go generate. Continue anyway.")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
 	// run binary build command
+	utility.Emit(utility.EventBuildStarted, "", utility.BuildStartedData{Plugin: pctx.manifest.Id, Binary: pctx.binPath})
+	start := time.Now()
 	err = pctx.runBinaryBuildCommand()
+	buildData := utility.BuildFinishedData{Plugin: pctx.manifest.Id, Binary: pctx.binPath}
+	buildData.Duration = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	buildData.Ok = err == nil
 	if err != nil {
-		fmt.Printf("Error running new binary build command: %v\n", err.Error())
-		pctx.skipRunDueToFailedComplile = true
-		return
+		buildData.Error = err.Error()
 	}
+	utility.Emit(utility.EventBuildFinished, "", buildData)
+	if err != nil {
+		return err
+	}
+
+	// cross compile any additional targets
+	err = pctx.runTargetBuilds(ctx)
+	if err != nil {
+		return err
+	}
+
+	// run post build hooks
+	err = pctx.runHooks(ctx, hook_post_build, pctx.hooks.PostBuild)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// copyFiles copies every plugin file along with the copy hooks.
+func (pctx *pluginContext) copyFiles(ctx context.Context) error {
 
+	// run pre copy hooks
+	err := pctx.runHooks(ctx, hook_pre_copy, pctx.hooks.PreCopy)
+	if err != nil {
+		return err
+	}
+
+	// don't copy anything from a stale build
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// copy files
 	err = pctx.copyPluginFiles()
 	if err != nil {
 		fmt.Printf("Error copying files: %v\n", err.Error())
+		return utility.NewError(utility.KindCopy, err)
 	}
 
-	// if we are suppose to run the new binary within gocms
-	pctx.skipRunDueToFailedComplile = false
-	if pctx.run {
-		newGoCMSDonChan := make(chan bool)
-		pctx.goCMSDoneChan = newGoCMSDonChan
-		pctx.runGoCMS()
-	} else {
-		fmt.Printf("Rebuild & Copy Complete - %v\n", time.Now().Format("03:04:05"))
+	// run post copy hooks
+	err = pctx.runHooks(ctx, hook_post_copy, pctx.hooks.PostCopy)
+	if err != nil {
+		return err
 	}
 
+	return nil
 }
 
 func (pctx *pluginContext) copyPluginFiles() error {
@@ -264,16 +429,7 @@ func (pctx *pluginContext) copyPluginFiles() error {
 			return err
 		}
 
-		destFile := file
-
-		// strip leading path if it isn't just a file
-		if filepath.Base(file) != file && pctx.srcDir != "." {
-			destFile = strings.Replace(file, pctx.srcDir, "", 1)
-			if pctx.verbose {
-				fmt.Printf("compaired %v and replaced %v, with %v\n", pctx.srcDir, file, destFile)
-			}
-		}
-		destFilePath := filepath.Join(pctx.pluginPath, destFile)
+		destFilePath := pctx.pluginDestPath(file)
 		err := utility.Copy(file, destFilePath, true, pctx.verbose)
 		if err != nil {
 			fmt.Printf("Error copying %v: %v\n", file, err.Error())
@@ -284,19 +440,29 @@ func (pctx *pluginContext) copyPluginFiles() error {
 	return nil
 }
 
-func (pctx *pluginContext) runBinaryBuildCommand() error {
-	fullBinPath := filepath.Join(pctx.pluginPath, pctx.manifest.Services.Bin)
-	if runtime.GOOS == "windows" {
-		fullBinPath = fmt.Sprintf("%v.exe", fullBinPath)
+// pluginDestPath maps a file to copy onto its location in the installed plugin.
+func (pctx *pluginContext) pluginDestPath(file string) string {
+	destFile := file
+
+	// strip leading path if it isn't just a file
+	if filepath.Base(file) != file && pctx.srcDir != "." {
+		destFile = strings.Replace(file, pctx.srcDir, "", 1)
+		if pctx.verbose {
+			fmt.Printf("compaired %v and replaced %v, with %v\n", pctx.srcDir, file, destFile)
+		}
 	}
 
+	return filepath.Join(pctx.pluginPath, destFile)
+}
+
+func (pctx *pluginContext) runBinaryBuildCommand() error {
 	err := pctx.goBuildExec.Run()
 	if err != nil {
-		fmt.Printf("Error running 'go build -o %v %v': %v\n", fullBinPath, filepath.Join(pctx.srcDir, pctx.buildEntry), err.Error())
+		fmt.Printf("Error running '%v': %v\n", strings.Join(pctx.goBuildExec.Args, " "), err.Error())
 		return err
 	}
 	// set permissions to run
-	err = os.Chmod(fullBinPath, os.FileMode(0755))
+	err = os.Chmod(pctx.stagedBinPath, os.FileMode(0755))
 	if err != nil {
 		fmt.Printf("Error setting plugin to executable: %v\n", err.Error())
 		return err
@@ -304,34 +470,127 @@ func (pctx *pluginContext) runBinaryBuildCommand() error {
 	return nil
 }
 
-func (pctx *pluginContext) runGoCMS() {
+func (pctx *pluginContext) runGoCMS() error {
 	fmt.Printf("Running GoCMS\n")
-	go utility.StartGoCMS(pctx.destDir, pctx.devMode, pctx.goCMSDoneChan)
+
+	options := utility.GoCMSOptions{DevMode: pctx.devMode}
+	if pctx.logs != nil {
+		options.Stdout = pctx.goCMSLogStream("gocms", utility.LogInfo)
+		options.Stderr = pctx.goCMSLogStream("gocms:err", utility.LogInfo)
+	}
+
+	goCMS, err := utility.NewGoCMSSupervisor(pctx.destDir, options)
+	if err != nil {
+		fmt.Printf("Error building gocms: %v\n", err.Error())
+		return err
+	}
+
+	err = goCMS.Start()
+	if err != nil {
+		fmt.Printf("Error starting gocms: %v\n", err.Error())
+		return err
+	}
+	go utility.PrintProcessEvents(goCMS)
+
+	pctx.goCMSLock.Lock()
+	pctx.goCMS = goCMS
+	pctx.goCMSLock.Unlock()
+
+	pctx.waitForReady()
+
+	return nil
+}
+
+// stopGoCMS stops gocms and waits for it to exit so the plugin binary can be replaced.
+func (pctx *pluginContext) stopGoCMS() {
+	pctx.cancelWaitForReady()
+
+	pctx.goCMSLock.Lock()
+	goCMS := pctx.goCMS
+	pctx.goCMS = nil
+	pctx.goCMSLock.Unlock()
+
+	if goCMS != nil {
+		fmt.Printf("Stopping GoCMS\n")
+		goCMS.Stop()
+	}
+}
+
+func (pctx *pluginContext) goCMSRunning() bool {
+	pctx.goCMSLock.Lock()
+	defer pctx.goCMSLock.Unlock()
+
+	return pctx.goCMS != nil && pctx.goCMS.State() == utility.ProcessRunning
 }
 
-func (pctx *pluginContext) getBinaryBuildCommand() error {
-	// build go binary exce
-	pctx.pluginPath = filepath.Join(pctx.destDir, config.CONTENT_DIR, config.PLUGINS_DIR, pctx.manifest.Id)
-	buildPath := filepath.Join(pctx.pluginPath, pctx.manifest.Services.Bin)
+// installBinary moves the freshly built binary into place. It reports whether the binary differs from
+// the one it replaced so callers can skip restarting GoCMS when nothing changed.
+func (pctx *pluginContext) installBinary() (bool, error) {
+	changed, err := pctx.stagedBinaryChanged()
+	if err != nil {
+		return false, err
+	}
+
+	// identical build, keep what is there
+	if !changed {
+		_ = os.Remove(pctx.stagedBinPath)
+		return false, nil
+	}
+
+	err = os.Rename(pctx.stagedBinPath, pctx.binPath)
+	if err != nil {
+		fmt.Printf("Error moving plugin binary into place: %v\n", err.Error())
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (pctx *pluginContext) stagedBinaryChanged() (bool, error) {
+	if _, err := os.Stat(pctx.binPath); os.IsNotExist(err) {
+		return true, nil
+	}
+
+	stagedChecksum, err := utility.FileChecksum(pctx.stagedBinPath)
+	if err != nil {
+		return false, err
+	}
+	currentChecksum, err := utility.FileChecksum(pctx.binPath)
+	if err != nil {
+		return false, err
+	}
+
+	return stagedChecksum != currentChecksum, nil
+}
+
+func (pctx *pluginContext) getBinaryBuildCommand(ctx context.Context) error {
+	// build go binary exce. the binary is built next to the real one and moved into place by installBinary
+	pctx.binPath = filepath.Join(pctx.pluginPath, pctx.manifest.Services.Bin)
+	pctx.stagedBinPath = filepath.Join(pctx.pluginPath, "."+pctx.manifest.Services.Bin+".build")
 	if runtime.GOOS == "windows" {
-		buildPath = fmt.Sprintf("%v.exe", buildPath)
+		pctx.binPath = fmt.Sprintf("%v.exe", pctx.binPath)
+		pctx.stagedBinPath = fmt.Sprintf("%v.exe", pctx.stagedBinPath)
+	}
+	pctx.goBuildExec = exec.CommandContext(ctx, "go", pctx.buildArgs(pctx.stagedBinPath, pctx.build.Race)...)
+	pctx.goBuildExec.Env = pctx.buildEnv()
+	if pctx.verbose {
+		fmt.Printf("Build command: %v\n", strings.Join(pctx.goBuildExec.Args, " "))
 	}
-	pctx.goBuildExec = exec.Command("go", "build", "-o", buildPath, filepath.Join(pctx.srcDir, pctx.buildEntry))
 	//if pctx.verbose {
-	pctx.goBuildExec.Stdout = os.Stdout
+	pctx.goBuildExec.Stdout = pctx.buildStdout
 	//}
-	pctx.goBuildExec.Stderr = os.Stderr
+	pctx.goBuildExec.Stderr = pctx.buildStderr
 
 	return nil
 }
 
-func (pctx *pluginContext) goGenerate() error {
+func (pctx *pluginContext) goGenerate(ctx context.Context) error {
 	// run go generate
-	goGenerate := exec.Command("go", "generate", filepath.Join(pctx.srcDir, pctx.buildEntry))
+	goGenerate := exec.CommandContext(ctx, "go", "generate", filepath.Join(pctx.srcDir, pctx.buildEntry))
 	if pctx.verbose {
-		goGenerate.Stdout = os.Stdout
+		goGenerate.Stdout = pctx.buildStdout
 	}
-	goGenerate.Stderr = os.Stderr
+	goGenerate.Stderr = pctx.buildStderr
 	err := goGenerate.Run()
 	if err != nil {
 		errStr := fmt.Sprintf("Error running 'go generate %v': %v\n", filepath.Join(pctx.srcDir, pctx.buildEntry), err.Error())
@@ -345,8 +604,12 @@ func (pctx *pluginContext) goGenerate() error {
 func buildContextFromFlags(c *cli.Context) (*pluginContext, error) {
 
 	// get src dir and dest dri
-	srcDir := c.Args().Get(0)
-	destDir := c.Args().Get(1)
+	args, err := sites.Args(c, 1)
+	if err != nil {
+		return nil, err
+	}
+	srcDir := args.Get(0)
+	destDir := args.Get(1)
 
 	// verify src and dest exist
 	if srcDir == "" || destDir == "" {
@@ -359,22 +622,16 @@ func buildContextFromFlags(c *cli.Context) (*pluginContext, error) {
 	srcDir = filepath.Clean(srcDir)
 	destDir = filepath.Clean(destDir)
 
-	// parse manifest file
-	manifestPath := filepath.Join(srcDir, "manifest.json")
-	manifest, err := utility.ParseManifest(manifestPath)
-	if err != nil {
-		fmt.Printf("Error parsing manifest file %v: %v\n", manifestPath, err.Error())
-		return nil, err
-	}
-
 	pctx := pluginContext{
-		buildEntry: "main.go",
-		manifest:   manifest,
-		devMode:    false,
-		run:        false,
-		watch:      false,
-		srcDir:     srcDir,
-		destDir:    destDir,
+		buildEntry:  "main.go",
+		devMode:     false,
+		run:         false,
+		watch:       false,
+		srcDir:      srcDir,
+		destDir:     destDir,
+		cliContext:  c,
+		buildStdout: os.Stdout,
+		buildStderr: os.Stderr,
 	}
 
 	// entry
@@ -396,28 +653,45 @@ func buildContextFromFlags(c *cli.Context) (*pluginContext, error) {
 	if c.Bool(flag_watch) {
 		pctx.watch = true
 	}
+	pctx.debounce = c.Duration(flag_debounce)
+
+	// hot reload
+	if c.Bool(flag_hot_reload) {
+		pctx.hotReload = true
+	}
+	pctx.goCMSUrl = c.String(flag_gocms_url)
+	if pctx.goCMSUrl == "" {
+		pctx.goCMSUrl = utility.GoCMSUrl(destDir)
+	}
+
+	// readiness
+	pctx.healthUrl = c.String(flag_health_url)
+	if pctx.healthUrl == "" {
+		pctx.healthUrl = pctx.goCMSUrl
+	}
+	pctx.readyTimeout = c.Duration(flag_ready_timeout)
 
 	// verbose
 	if c.GlobalBool(config.FLAG_VERBOSE) {
 		pctx.verbose = true
 	}
 
-	// add default files
-	pctx.filesToCopy = append(pctx.filesToCopy, filepath.Join(pctx.srcDir, config.PLUGIN_MANIFEST))
-
-	// add docs if they exist
-	if pctx.manifest.Services.Docs != "" {
-		pctx.filesToCopy = append(pctx.filesToCopy, filepath.Join(pctx.srcDir, pctx.manifest.Services.Docs))
+	// package
+	if c.String(flag_package) != "" {
+		if utility.ArchiveFormatFromName(c.String(flag_package)) == utility.ArchiveUnknown {
+			errStr := fmt.Sprintf("Can't package plugin as %v. Use a .zip, .tar, .tar.gz or .tar.zst file.", c.String(flag_package))
+			fmt.Println(errStr)
+			return nil, errors.New(errStr)
+		}
+		pctx.packagePath = filepath.Clean(c.String(flag_package))
 	}
 
-	// add additional files
-	if c.StringSlice(flag_dir_file_to_copy) != nil {
-		pctx.filesToCopy = append(pctx.filesToCopy, c.StringSlice(flag_dir_file_to_copy)...)
+	// manifest, plugin config and the files to copy
+	err = pctx.loadManifest()
+	if err != nil {
+		return nil, err
 	}
 
-	// add interface files as needed
-	pctx.load_interface_for_plugin()
-
 	// add default ignore files
 	pctx.ignorePath = append(pctx.ignorePath, []string{"vendor", ".git", "docs", ".idea", "___*", "node_modules"}...)
 
@@ -425,10 +699,71 @@ func buildContextFromFlags(c *cli.Context) (*pluginContext, error) {
 	if c.StringSlice(flag_ignore_files) != nil {
 		pctx.ignorePath = append(pctx.ignorePath, c.StringSlice(flag_ignore_files)...)
 	}
+	for _, ignorePath := range pctx.ignorePath {
+		ignoreRegex, err := regexp.Compile(ignorePath)
+		if err != nil {
+			errStr := fmt.Sprintf("Invalid ignore pattern '%v': %v", ignorePath, err.Error())
+			fmt.Println(errStr)
+			return nil, utility.NewError(utility.KindUsage, errors.New(errStr))
+		}
+		pctx.ignoreRegex = append(pctx.ignoreRegex, ignoreRegex)
+	}
 
 	return &pctx, nil
 }
 
+// loadManifest parses the plugin manifest and everything derived from it. It is called again
+// whenever the manifest or gcm.yaml changes while watching.
+func (pctx *pluginContext) loadManifest() error {
+
+	// parse manifest file
+	manifestPath := filepath.Join(pctx.srcDir, config.PLUGIN_MANIFEST)
+	manifest, err := utility.ParseManifest(manifestPath)
+	if err != nil {
+		fmt.Printf("Error parsing manifest file %v: %v\n", manifestPath, err.Error())
+		return err
+	}
+	pctx.manifest = manifest
+	pctx.pluginPath = filepath.Join(utility.ActiveInstallDir(pctx.destDir), config.CONTENT_DIR, config.PLUGINS_DIR, pctx.manifest.Id)
+
+	// remember the manifest as loaded to tell real changes from touches
+	pctx.manifestChecksum, err = utility.FileChecksum(manifestPath)
+	if err != nil {
+		fmt.Printf("Error reading manifest file %v: %v\n", manifestPath, err.Error())
+		return err
+	}
+
+	// load hooks and build settings
+	err = pctx.loadPluginConfig()
+	if err != nil {
+		return err
+	}
+
+	// build flags
+	err = pctx.applyBuildFlags(pctx.cliContext)
+	if err != nil {
+		return err
+	}
+
+	// add default files
+	pctx.filesToCopy = []string{filepath.Join(pctx.srcDir, config.PLUGIN_MANIFEST)}
+
+	// add docs if they exist
+	if pctx.manifest.Services.Docs != "" {
+		pctx.filesToCopy = append(pctx.filesToCopy, filepath.Join(pctx.srcDir, pctx.manifest.Services.Docs))
+	}
+
+	// add additional files
+	if pctx.cliContext.StringSlice(flag_dir_file_to_copy) != nil {
+		pctx.filesToCopy = append(pctx.filesToCopy, pctx.cliContext.StringSlice(flag_dir_file_to_copy)...)
+	}
+
+	// add interface files as needed
+	pctx.load_interface_for_plugin()
+
+	return nil
+}
+
 func (pctx *pluginContext) load_interface_for_plugin() {
 
 	// public
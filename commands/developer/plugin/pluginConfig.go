@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/models"
+	"github.com/gocms-io/gcm/utility"
+	"os"
+	"path/filepath"
+)
+
+// loadPluginConfig reads gcm.yaml from the plugin source if there is one.
+// Hooks from gcm.yaml run after those declared in the manifest for the same stage.
+func (pctx *pluginContext) loadPluginConfig() error {
+	pctx.hooks = pctx.manifest.Hooks
+	pctx.build = models.PluginBuild{}
+
+	pluginConfigPath := filepath.Join(pctx.srcDir, config.PLUGIN_CONFIG)
+	if _, err := os.Stat(pluginConfigPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	pluginConfig, err := utility.ParsePluginConfig(pluginConfigPath)
+	if err != nil {
+		fmt.Printf("Error parsing plugin config file %v: %v\n", pluginConfigPath, err.Error())
+		return err
+	}
+	pctx.hooks = mergeHooks(pctx.manifest.Hooks, pluginConfig.Hooks)
+	pctx.build = pluginConfig.Build
+
+	return nil
+}
+
+// mergeHooks returns the hooks of first followed by those of second for every stage.
+func mergeHooks(first models.PluginHooks, second models.PluginHooks) models.PluginHooks {
+	return models.PluginHooks{
+		PreBuild:  append(append([]*models.PluginHook{}, first.PreBuild...), second.PreBuild...),
+		PostBuild: append(append([]*models.PluginHook{}, first.PostBuild...), second.PostBuild...),
+		PreCopy:   append(append([]*models.PluginHook{}, first.PreCopy...), second.PreCopy...),
+		PostCopy:  append(append([]*models.PluginHook{}, first.PostCopy...), second.PostCopy...),
+		PreRun:    append(append([]*models.PluginHook{}, first.PreRun...), second.PreRun...),
+	}
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/utility"
+	"time"
+)
+
+// waitForReady waits in the background for gocms to come up and then checks the plugin routes.
+// Stopping gocms cancels the wait.
+func (pctx *pluginContext) waitForReady() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pctx.goCMSLock.Lock()
+	if pctx.readyCancel != nil {
+		pctx.readyCancel()
+	}
+	pctx.readyCancel = cancel
+	pctx.goCMSLock.Unlock()
+
+	// copy what is needed since the manifest can be reloaded while waiting
+	goCMSUrl := pctx.goCMSUrl
+	healthUrl := pctx.healthUrl
+	readyTimeout := pctx.readyTimeout
+	pluginId := pctx.manifest.Id
+	routes := pctx.manifest.Services.Routes
+
+	go func() {
+		start := time.Now()
+		err := utility.WaitForReady(ctx, healthUrl, readyTimeout)
+		if ctx.Err() == context.Canceled {
+			return
+		}
+		if err != nil {
+			fmt.Printf("GoCMS is not ready: %v\n", err.Error())
+			return
+		}
+
+		utility.PrintReadyBanner(goCMSUrl, time.Since(start))
+		utility.PrintRouteChecks(pluginId, utility.CheckRoutes(ctx, goCMSUrl, routes))
+	}()
+}
+
+func (pctx *pluginContext) cancelWaitForReady() {
+	pctx.goCMSLock.Lock()
+	defer pctx.goCMSLock.Unlock()
+
+	if pctx.readyCancel != nil {
+		pctx.readyCancel()
+		pctx.readyCancel = nil
+	}
+}
@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gocms/utility/errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type buildTarget struct {
+	goos   string
+	goarch string
+	osPath string
+}
+
+type buildTargetResult struct {
+	target   *buildTarget
+	binPath  string
+	duration time.Duration
+	output   []byte
+	err      error
+}
+
+func (t *buildTarget) String() string {
+	return fmt.Sprintf("%v/%v", t.goos, t.goarch)
+}
+
+// isHost reports whether the target is the machine gcm runs on. Only the host can be built with the race detector.
+func (t *buildTarget) isHost() bool {
+	return t.goos == runtime.GOOS && t.goarch == runtime.GOARCH
+}
+
+// parseBuildTargets turns a list of GOOS/GOARCH pairs into build targets. Each entry may hold several comma separated targets.
+func parseBuildTargets(targets []string) ([]*buildTarget, error) {
+	var buildTargets []*buildTarget
+	seen := make(map[string]bool)
+
+	for _, entry := range targets {
+		for _, target := range strings.Split(entry, ",") {
+			target = strings.TrimSpace(target)
+			if target == "" || seen[target] {
+				continue
+			}
+
+			osPath, ok := config.BUILD_TARGETS[target]
+			if !ok {
+				var supported []string
+				for t := range config.BUILD_TARGETS {
+					supported = append(supported, t)
+				}
+				sort.Strings(supported)
+				errStr := fmt.Sprintf("Unsupported build target '%v'. Supported targets: %v", target, strings.Join(supported, ", "))
+				fmt.Println(errStr)
+				return nil, errors.New(errStr)
+			}
+
+			parts := strings.SplitN(target, "/", 2)
+			buildTargets = append(buildTargets, &buildTarget{
+				goos:   parts[0],
+				goarch: parts[1],
+				osPath: osPath,
+			})
+			seen[target] = true
+		}
+	}
+
+	return buildTargets, nil
+}
+
+// runTargetBuilds builds the plugin binary for every target in parallel into <plugin>/<os path>/<bin>.
+func (pctx *pluginContext) runTargetBuilds(ctx context.Context) error {
+	if len(pctx.targets) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Building %v targets...\n", len(pctx.targets))
+	if pctx.build.Race {
+		fmt.Printf("Targets other than %v/%v are built without -race since the race detector only runs on the host.\n", runtime.GOOS, runtime.GOARCH)
+	}
+
+	results := make([]*buildTargetResult, len(pctx.targets))
+	var wg sync.WaitGroup
+	for i, target := range pctx.targets {
+		wg.Add(1)
+		go func(i int, target *buildTarget) {
+			defer wg.Done()
+			results[i] = pctx.buildForTarget(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return printTargetBuildSummary(results)
+}
+
+func (pctx *pluginContext) buildForTarget(ctx context.Context, target *buildTarget) *buildTargetResult {
+	binPath := filepath.Join(pctx.pluginPath, target.osPath, pctx.manifest.Services.Bin)
+	if target.goos == "windows" {
+		binPath = fmt.Sprintf("%v.exe", binPath)
+	}
+
+	result := &buildTargetResult{
+		target:  target,
+		binPath: binPath,
+	}
+	start := time.Now()
+
+	// output is captured since all targets build at once
+	goBuildExec := exec.CommandContext(ctx, "go", pctx.buildArgs(binPath, pctx.build.Race && target.isHost())...)
+	goBuildExec.Env = append(pctx.buildEnv(), "GOOS="+target.goos, "GOARCH="+target.goarch)
+	result.output, result.err = goBuildExec.CombinedOutput()
+	result.duration = time.Since(start)
+	if result.err != nil {
+		return result
+	}
+
+	// set permissions to run
+	result.err = os.Chmod(binPath, os.FileMode(0755))
+
+	return result
+}
+
+func printTargetBuildSummary(results []*buildTargetResult) error {
+	failed := 0
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tSTATUS\tTIME\tOUTPUT")
+	for _, result := range results {
+		status := "ok"
+		if result.err != nil {
+			status = "failed"
+			failed++
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", result.target, status, result.duration.Round(time.Millisecond), result.binPath)
+	}
+	w.Flush()
+
+	// show why builds failed
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Printf("\n%v failed: %v\n%s", result.target, result.err.Error(), result.output)
+		}
+	}
+
+	if failed > 0 {
+		errStr := fmt.Sprintf("%v of %v target builds failed", failed, len(results))
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// changeSet sorts a batch of changed paths by what has to happen because of them.
+type changeSet struct {
+	manifest bool
+	config   bool
+	source   bool
+	assets   []string
+	ignored  []string
+}
+
+// startFileWatcher feeds changes to the build scheduler, which must already exist.
+func (pctx *pluginContext) startFileWatcher() {
+
+	pctx.watcherFileContext = &utility.WatchFileContext{
+		Verbose:     pctx.verbose,
+		SourceBase:  pctx.srcDir,
+		IgnorePaths: pctx.ignorePath,
+		DoneChan:    pctx.watcherDoneChan,
+		Chmod:       utility.IgnoreDestination,
+		Removed:     pctx.onFileChangeHandler,
+		Create:      pctx.onFileChangeHandler,
+		Rename:      utility.IgnoreDestination,
+		Write:       pctx.onFileChangeHandler,
+	}
+
+	if pctx.devMode {
+		fmt.Printf("Dev mode enabled. Waiting 5 seconds before watching files for change.\n")
+		time.Sleep(time.Second * 5)
+	}
+	go pctx.watcherFileContext.Watch()
+}
+
+func (pctx *pluginContext) onFileChangeHandler(c *utility.WatchFileContext, eventPath string) {
+
+	// ignore changes to "."
+	if eventPath == "." || eventPath == "/" || eventPath == "./" || eventPath == "" {
+		return
+	}
+
+	// ignore paths as specified
+	for _, ignorePathRegex := range pctx.ignoreRegex {
+		if ignorePathRegex.MatchString(filepath.Clean(eventPath)) {
+			return
+		}
+	}
+
+	if pctx.verbose {
+		fmt.Printf("Change detected in '%v'\n", eventPath)
+	}
+
+	// changes are coalesced by the scheduler which calls rebuild once things settle down
+	pctx.buildScheduler.Notify(eventPath)
+}
+
+// rebuild handles a batch of changes. It is only ever called by the build scheduler, which guarantees
+// a single rebuild at a time and cancels ctx when newer changes arrive. Go sources rebuild the binary,
+// assets are copied one by one and manifest changes reload the plugin. GoCMS is only restarted when
+// the binary or the manifest actually changed.
+func (pctx *pluginContext) rebuild(ctx context.Context, changes []string) {
+
+	fmt.Printf("Changes Detected in %v\n", strings.Join(changes, ", "))
+
+	cs := pctx.classifyChanges(changes)
+	if pctx.verbose && len(cs.ignored) > 0 {
+		fmt.Printf("Nothing to do for %v\n", strings.Join(cs.ignored, ", "))
+	}
+
+	fmt.Printf("Start Rebuild & Copy - %v\n", time.Now().Format("03:04:05"))
+
+	// reload the manifest and everything derived from it
+	manifestChanged := false
+	if cs.manifest || cs.config {
+		previousChecksum := pctx.manifestChecksum
+		err := pctx.loadManifest()
+		if err != nil {
+			fmt.Printf("Rebuild & Copy Failed: %v\n", err.Error())
+			return
+		}
+		manifestChanged = pctx.manifestChecksum != previousChecksum
+	}
+
+	// build settings live in the manifest and gcm.yaml so both need a new binary
+	rebuildBinary := cs.source || manifestChanged || cs.config
+	if rebuildBinary {
+		err := pctx.buildBinary(ctx)
+		if err != nil {
+			pctx.printRebuildError(ctx, err)
+			return
+		}
+	}
+
+	// the file list may be different after a manifest change so copy everything
+	var err error
+	if manifestChanged || cs.config {
+		err = pctx.copyFiles(ctx)
+	} else if len(cs.assets) > 0 {
+		err = pctx.copyChangedFiles(ctx, cs.assets)
+	}
+	if err != nil {
+		pctx.printRebuildError(ctx, err)
+		return
+	}
+
+	binaryChanged := false
+	if rebuildBinary {
+		binaryChanged, err = pctx.stagedBinaryChanged()
+		if err != nil {
+			fmt.Printf("Rebuild & Copy Failed: %v\n", err.Error())
+			return
+		}
+	}
+
+	restart := pctx.run && (binaryChanged || manifestChanged || !pctx.goCMSRunning())
+
+	// swap just the plugin process when gocms supports it
+	runPreRunHooks := true
+	if restart && pctx.canHotReload(manifestChanged) {
+		if rebuildBinary {
+			_, err = pctx.installBinary()
+			if err != nil {
+				fmt.Printf("Rebuild & Copy Failed: %v\n", err.Error())
+				return
+			}
+			rebuildBinary = false
+		}
+
+		err = pctx.runHooks(ctx, hook_pre_run, pctx.hooks.PreRun)
+		if err != nil {
+			return
+		}
+		runPreRunHooks = false
+
+		err = pctx.reloadPlugin()
+		if err == nil {
+			fmt.Printf("Rebuild & Copy Complete - %v\n", time.Now().Format("03:04:05"))
+			return
+		}
+		fmt.Printf("Hot reload failed: %v. Restarting GoCMS.\n", err.Error())
+	}
+
+	// gocms has to let go of the old binary before it is replaced
+	if restart {
+		pctx.stopGoCMS()
+	}
+
+	if rebuildBinary {
+		_, err = pctx.installBinary()
+		if err != nil {
+			fmt.Printf("Rebuild & Copy Failed: %v\n", err.Error())
+			return
+		}
+	}
+
+	// if we are suppose to run the new binary within gocms
+	if restart {
+		if runPreRunHooks {
+			err = pctx.runHooks(ctx, hook_pre_run, pctx.hooks.PreRun)
+			if err != nil {
+				return
+			}
+		}
+
+		_ = pctx.runGoCMS()
+		return
+	}
+
+	if pctx.run && rebuildBinary {
+		fmt.Printf("Binary and manifest unchanged. GoCMS left running.\n")
+	}
+	fmt.Printf("Rebuild & Copy Complete - %v\n", time.Now().Format("03:04:05"))
+}
+
+func (pctx *pluginContext) printRebuildError(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		fmt.Printf("Rebuild cancelled. Newer changes detected.\n")
+		return
+	}
+	fmt.Printf("Rebuild & Copy Failed: %v\n", err.Error())
+}
+
+func (pctx *pluginContext) classifyChanges(changes []string) *changeSet {
+	cs := &changeSet{}
+
+	manifestPath := filepath.Join(pctx.srcDir, config.PLUGIN_MANIFEST)
+	pluginConfigPath := filepath.Join(pctx.srcDir, config.PLUGIN_CONFIG)
+
+	for _, change := range changes {
+		change = filepath.Clean(change)
+		base := filepath.Base(change)
+
+		switch {
+		case change == manifestPath:
+			cs.manifest = true
+		case change == pluginConfigPath:
+			cs.config = true
+		case filepath.Ext(change) == ".go" || base == "go.mod" || base == "go.sum":
+			cs.source = true
+		case pctx.fileToCopyFor(change) != "":
+			cs.assets = append(cs.assets, change)
+		default:
+			cs.ignored = append(cs.ignored, change)
+		}
+	}
+
+	return cs
+}
+
+// fileToCopyFor returns the entry in the copy list that a changed path belongs to.
+func (pctx *pluginContext) fileToCopyFor(changedPath string) string {
+	for _, file := range pctx.filesToCopy {
+		rel, err := filepath.Rel(filepath.Clean(file), changedPath)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))) {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// copyChangedFiles copies only the changed assets into the plugin and removes the ones that are gone.
+func (pctx *pluginContext) copyChangedFiles(ctx context.Context, assets []string) error {
+
+	// run pre copy hooks
+	err := pctx.runHooks(ctx, hook_pre_copy, pctx.hooks.PreCopy)
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range assets {
+		file := pctx.fileToCopyFor(asset)
+		rel, _ := filepath.Rel(filepath.Clean(file), asset)
+		dest := filepath.Join(pctx.pluginDestPath(file), rel)
+
+		if _, err := os.Stat(asset); os.IsNotExist(err) {
+			err = os.RemoveAll(dest)
+			if err != nil {
+				fmt.Printf("Error removing %v: %v\n", dest, err.Error())
+				return err
+			}
+			fmt.Printf("Removed %v\n", dest)
+			continue
+		}
+
+		err = utility.Copy(asset, dest, true, pctx.verbose)
+		if err != nil {
+			fmt.Printf("Error copying %v: %v\n", asset, err.Error())
+			return err
+		}
+		fmt.Printf("Copied %v\n", asset)
+	}
+
+	// run post copy hooks
+	err = pctx.runHooks(ctx, hook_post_copy, pctx.hooks.PostCopy)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package theme
 
 import (
 	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
 	"github.com/gocms-io/gcm/config"
 	"github.com/gocms-io/gcm/utility"
 	"github.com/gocms-io/gocms/utility/errors"
@@ -46,19 +47,23 @@ var CMD_THEME = cli.Command{
 func cmd_copy_theme(c *cli.Context) error {
 
 	// verify there is a source and destination
-	if !c.Args().Present() {
+	args, err := sites.Args(c, 1)
+	if err != nil {
+		return err
+	}
+	if !args.Present() {
 		err := "A source and destination directory must be specified."
 		fmt.Println(err)
-		return errors.New(err)
+		return utility.NewError(utility.KindUsage, errors.New(err))
 	}
 
-	srcDir := c.Args().Get(0)
-	destDir := c.Args().Get(1)
+	srcDir := args.Get(0)
+	destDir := args.Get(1)
 
 	if srcDir == "" || destDir == "" {
 		err := "A source and destination directory must be specified."
 		fmt.Println(err)
-		return errors.New(err)
+		return utility.NewError(utility.KindUsage, errors.New(err))
 	}
 
 	if srcDir == "." || srcDir == "./" {
@@ -69,7 +74,7 @@ func cmd_copy_theme(c *cli.Context) error {
 	if c.String(theme_name) == "" {
 		err := "A plugin name must be specified with the --name or -n flag."
 		fmt.Println(err)
-		return errors.New(err)
+		return utility.NewError(utility.KindUsage, errors.New(err))
 	}
 
 	// ignore files
@@ -83,12 +88,12 @@ func cmd_copy_theme(c *cli.Context) error {
 	}
 
 	themeName := c.String(theme_name)
-	themeDirPath := filepath.Join(destDir, config.CONTENT_DIR, config.THEMES_DIR, themeName)
+	themeDirPath := filepath.Join(utility.ActiveInstallDir(destDir), config.CONTENT_DIR, config.THEMES_DIR, themeName)
 
-	err := utility.Copy(filepath.Clean(srcDir), themeDirPath, c.Bool(flag_hard), c.GlobalBool(config.FLAG_VERBOSE), ignorePath...)
+	err = utility.Copy(filepath.Clean(srcDir), themeDirPath, c.Bool(flag_hard), c.GlobalBool(config.FLAG_VERBOSE), ignorePath...)
 	if err != nil {
 		fmt.Printf("Error copying theme dir: %v\n", err.Error())
-		return err
+		return utility.NewError(utility.KindCopy, err)
 	}
 
 	if c.Bool(flag_watch) {
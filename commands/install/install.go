@@ -2,72 +2,320 @@ package install
 
 import (
 	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
 	"github.com/gocms-io/gcm/config"
 	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
 	"github.com/urfave/cli"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
+const flag_archive = "archive"
+const flag_from_dir = "from-dir"
+const flag_releases = "releases"
+
+// ArchiveFlag and FromDirFlag are shared with update so both install from the same sources.
+var ArchiveFlag = cli.StringFlag{
+	Name:  flag_archive,
+	Usage: "Install from a release archive on disk instead of downloading one.",
+}
+
+var FromDirFlag = cli.StringFlag{
+	Name:  flag_from_dir,
+	Usage: "Install from an already extracted release directory instead of downloading one.",
+}
+
 var CMD_INSTALL = cli.Command{
 	Name:      "install",
 	Usage:     "Install gocms",
 	ArgsUsage: "<directory>",
 	Action:    cmd_install,
+	Flags: []cli.Flag{
+		ArchiveFlag,
+		FromDirFlag,
+		cli.BoolFlag{
+			Name:  flag_releases,
+			Usage: "Install into releases/<name> behind a current symlink so updates switch releases atomically and can be rolled back. .env, plugins and themes other than the default one are kept in shared/. Not available on windows.",
+		},
+	},
 }
 
-func cmd_install(c *cli.Context) error {
+// InstallSource says where a release comes from. Without an archive or directory the release is downloaded.
+type InstallSource struct {
+	Version string
+	Archive string
+	Dir     string
+}
+
+func (source InstallSource) String() string {
+	switch {
+	case source.Archive != "":
+		return source.Archive
+	case source.Dir != "":
+		return source.Dir
+	}
+	return "version " + source.Version
+}
 
-	if !c.Args().Present() {
-		fmt.Println("An install directory must be specified.")
-		return nil
+// InstalledVersion is the gocms version source put in installDir. A release.json saying otherwise wins, and
+// local archives and directories are only known by theirs.
+func (source InstallSource) InstalledVersion(installDir string) string {
+	manifest, err := utility.ParseReleaseManifest(filepath.Join(utility.ActiveInstallDir(installDir), config.RELEASE_MANIFEST))
+	if err == nil && manifest.Version != "" {
+		return manifest.Version
+	}
+	if source.Archive != "" || source.Dir != "" {
+		return ""
 	}
+	return source.Version
+}
 
-	versionToUse := config.BINARY_DEFAULT_VERSION
+// SourceFromFlags reads the install source from --archive, --from-dir and --useVersion.
+func SourceFromFlags(c *cli.Context) (InstallSource, error) {
+	source := InstallSource{
+		Version: config.BINARY_DEFAULT_VERSION,
+		Archive: c.String(flag_archive),
+		Dir:     c.String(flag_from_dir),
+	}
 	if c.GlobalString(config.FLAG_SET_VERSION) != "" {
-		versionToUse = c.GlobalString(config.FLAG_SET_VERSION)
+		source.Version = c.GlobalString(config.FLAG_SET_VERSION)
+	}
+
+	if source.Archive != "" && source.Dir != "" {
+		errStr := fmt.Sprintf("Only one of --%v and --%v can be given.", flag_archive, flag_from_dir)
+		fmt.Println(errStr)
+		return source, utility.NewError(utility.KindUsage, errors.New(errStr))
 	}
 
-	err := BasicInstall(c.Args().First(), versionToUse)
+	// make paths absolute so they survive changing directories
+	for _, p := range []*string{&source.Archive, &source.Dir} {
+		if *p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*p)
+		if err != nil {
+			return source, utility.NewError(utility.KindUsage, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			errStr := fmt.Sprintf("Can't use %v: %v", *p, err.Error())
+			fmt.Println(errStr)
+			return source, utility.NewError(utility.KindUsage, errors.New(errStr))
+		}
+		*p = abs
+	}
+
+	return source, nil
+}
+
+func cmd_install(c *cli.Context) error {
+
+	args, err := sites.Args(c, 0)
 	if err != nil {
-		return nil
+		return err
+	}
+	if !args.Present() {
+		errStr := "An install directory must be specified."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	// the release layout needs symlinks, which windows only lets elevated users create, and renaming a link
+	// over the current one, which windows can't do
+	if c.Bool(flag_releases) && runtime.GOOS == "windows" {
+		errStr := fmt.Sprintf("--%v isn't supported on windows. Install without it to update in place.", flag_releases)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	source, err := SourceFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flag_releases) {
+		release, err := ReleaseInstall(args.First(), source, c.GlobalBool(config.FLAG_VERBOSE))
+		if err != nil {
+			return err
+		}
+		utility.SetResult("release", release)
+	} else {
+		err = BasicInstall(args.First(), source, c.GlobalBool(config.FLAG_VERBOSE))
+		if err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("GoCMS Installed Successfully!")
+	utility.SetResult("directory", args.First())
+	utility.SetResult("version", source.InstalledVersion(args.First()))
+	utility.SetResult("source", source.String())
 
 	return nil
 }
 
-func BasicInstall(installPath string, versionToUse string) error {
+// BasicInstall puts a release into installPath. Releases are downloaded unless source names an archive or directory.
+func BasicInstall(installPath string, source InstallSource, verbose bool) error {
+	installPath = filepath.Clean(installPath)
+
+	var err error
+	switch {
+	case source.Archive != "":
+		err = unpackRelease(source.Archive, installPath)
+	case source.Dir != "":
+		fmt.Printf("Copying release from %v to %v\n", source.Dir, installPath)
+		err = utility.Copy(source.Dir, installPath, false, verbose)
+		if err != nil {
+			fmt.Printf("Error copying GoCMS release: %v\n", err.Error())
+			err = utility.NewError(utility.KindCopy, err)
+		}
+	default:
+		err = downloadRelease(installPath, source.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = verifyRelease(installPath)
+	if err != nil {
+		return err
+	}
+
+	// remember what was installed for 'gcm version' and 'gcm status'
+	err = utility.WriteInstallInfo(installPath, utility.InstallInfo{
+		Version:   source.InstalledVersion(installPath),
+		Source:    source.String(),
+		Installed: time.Now(),
+	})
+	if err != nil {
+		fmt.Printf("Error recording the installed version: %v\n", err.Error())
+	}
+	return nil
+}
+
+// ReleaseInstall puts a release into its own directory under siteDir/releases, links in the shared files and
+// switches the current link to it. The previous release is left untouched so it can be switched back to.
+func ReleaseInstall(siteDir string, source InstallSource, verbose bool) (string, error) {
+	name, err := PrepareRelease(siteDir, source, verbose)
+	if err != nil {
+		return "", err
+	}
+
+	err = SwitchToRelease(siteDir, name)
+	if err != nil {
+		_ = os.RemoveAll(utility.ReleaseDir(siteDir, name))
+		return "", err
+	}
+
+	return name, nil
+}
+
+// PrepareRelease installs a release next to the current one and links in the shared files without switching to it.
+func PrepareRelease(siteDir string, source InstallSource, verbose bool) (string, error) {
+	name := utility.NewReleaseName(source.Version)
+	releaseDir := utility.ReleaseDir(siteDir, name)
+	if _, err := os.Lstat(releaseDir); err == nil {
+		errStr := fmt.Sprintf("Release %v already exists.", releaseDir)
+		fmt.Println(errStr)
+		return "", utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	err := BasicInstall(releaseDir, source, verbose)
+	if err != nil {
+		_ = os.RemoveAll(releaseDir)
+		return "", err
+	}
+
+	fmt.Printf("Linking shared files into release %v\n", name)
+	err = utility.LinkSharedPaths(siteDir, releaseDir)
+	if err != nil {
+		fmt.Printf("Error linking shared files: %v\n", err.Error())
+		_ = os.RemoveAll(releaseDir)
+		return "", utility.NewError(utility.KindCopy, err)
+	}
+
+	return name, nil
+}
+
+func SwitchToRelease(siteDir string, name string) error {
+	fmt.Printf("Switching %v to release %v\n", config.CURRENT_RELEASE_LINK, name)
+	err := utility.SwitchRelease(siteDir, name)
+	if err != nil {
+		fmt.Printf("Error switching release: %v\n", err.Error())
+		return utility.NewError(utility.KindCopy, err)
+	}
+	return nil
+}
+
+func downloadRelease(installPath string, versionToUse string) error {
 
 	// download file
 	downloadPath := path.Clean(installPath)
-	downloadLocation := fmt.Sprintf("%v/%v", downloadPath, config.BINARY_ARCHIVE)
+	urlLocation := utility.FindReleaseUrl(versionToUse)
+	downloadLocation := fmt.Sprintf("%v/%v", downloadPath, path.Base(urlLocation))
 	downloadLocation = filepath.FromSlash(downloadLocation)
-	urlLocation := fmt.Sprintf("%v://%v.%v/%v/%v/%v/%v", config.BINARY_PROTOCOL, config.BINARY_HOST, config.BINARY_DOMAIN, config.BINARY_DEFAULT_RELEASE, versionToUse, config.BINARY_OS_PATH, config.BINARY_ARCHIVE)
+	checksum, err := utility.FetchChecksum(urlLocation)
+	if err != nil {
+		fmt.Printf("Error fetching the checksum of the GoCMS package: %v\n", err.Error())
+		return err
+	}
+	if checksum == "" {
+		fmt.Printf("No checksum is published for %v. It can't be verified.\n", urlLocation)
+	}
 	fmt.Printf("Downloading: %v...\n", urlLocation)
-	err := utility.DownloadFile(downloadLocation, urlLocation)
+	err = utility.FetchFile(downloadLocation, urlLocation, checksum)
 	if err != nil {
 		fmt.Printf("Error downloading GoCMS package: %v\n", err.Error())
 		fmt.Printf("cleaning up files at %v\n", downloadLocation)
 		_ = os.Remove(downloadLocation)
-		return nil
+		return utility.NewError(utility.KindDownload, err)
 	}
 
 	// unzip file
-	fmt.Printf("Unpacking %v to %v\n", downloadLocation, downloadPath)
-	err = utility.Unzip(downloadLocation, downloadPath)
-	if err != nil {
-		fmt.Printf("Error unpacking GoCMS package: %v\n", err.Error())
-		fmt.Printf("cleaning up files at %v\n", downloadLocation)
-		_ = os.Remove(downloadLocation)
-		return err
-	}
+	err = unpackRelease(downloadLocation, downloadPath)
 
 	// clean up zip file
 	fmt.Printf("Cleaning up files at %v\n", downloadPath)
 	_ = os.Remove(downloadLocation)
 
+	return err
+}
+
+func unpackRelease(archive string, installPath string) error {
+	fmt.Printf("Unpacking %v to %v\n", archive, installPath)
+	err := utility.ExtractArchive(archive, installPath)
+	if err != nil {
+		fmt.Printf("Error unpacking GoCMS package: %v\n", err.Error())
+		return utility.NewError(utility.KindUnpack, err)
+	}
 	return nil
 }
+
+// verifyRelease checks that what was installed is a gocms release.
+func verifyRelease(installPath string) error {
+	if _, err := os.Stat(filepath.Join(installPath, config.BINARY_FILE)); err != nil {
+		errStr := fmt.Sprintf("%v doesn't contain a GoCMS release. %v is missing.", installPath, config.BINARY_FILE)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	warnIfGcmTooOld(installPath)
+	return nil
+}
+
+// warnIfGcmTooOld points out when the release asks for a newer gcm than this one. The install goes ahead
+// since an older gcm may still manage, ex: when the release adds a feature that isn't used.
+func warnIfGcmTooOld(installPath string) {
+	manifest, err := utility.ParseReleaseManifest(filepath.Join(installPath, config.RELEASE_MANIFEST))
+	if err != nil || manifest.MinGcmVersion == "" {
+		return
+	}
+	if utility.CompareVersions(config.GCM_VERSION, manifest.MinGcmVersion) >= 0 {
+		return
+	}
+	fmt.Printf("Warning: this release needs gcm %v or newer but this is gcm %v. Run 'gcm self-update' to update gcm.\n", manifest.MinGcmVersion, config.GCM_VERSION)
+	utility.SetResult("minGcmVersion", manifest.MinGcmVersion)
+}
@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/models"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const flag_force = "force"
+
+var CMD_INSTALL = cli.Command{
+	Name:      "install",
+	Usage:     "Install a plugin package made with 'gcm developer plugin --package' into content/plugins/<id> of an installation. The package can be a file or an http(s) url. Restart gocms to load it.",
+	ArgsUsage: "<package> <gocms installation>",
+	Action:    cmd_install,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  flag_force,
+			Usage: "Replace the plugin when it is already installed.",
+		},
+	},
+}
+
+func cmd_install(c *cli.Context) error {
+
+	args, err := sites.Args(c, 1)
+	if err != nil {
+		return err
+	}
+	pkg := args.Get(0)
+	siteDir := args.Get(1)
+	if pkg == "" || siteDir == "" {
+		errStr := "A plugin package and destination directory must be specified."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+	siteDir = filepath.Clean(siteDir)
+
+	installDir := utility.ActiveInstallDir(siteDir)
+	if _, err := os.Stat(filepath.Join(installDir, config.BINARY_FILE)); err != nil {
+		errStr := fmt.Sprintf("%v doesn't appear to be a GoCMS installation.", siteDir)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	// unpack next to the installed plugins so the plugin can be moved into place in one go. shared/ is resolved
+	// so the move doesn't cross a link.
+	pluginsDir := filepath.Join(installDir, config.CONTENT_DIR, config.PLUGINS_DIR)
+	err = os.MkdirAll(pluginsDir, os.ModePerm)
+	if err != nil {
+		fmt.Printf("Error creating %v: %v\n", pluginsDir, err.Error())
+		return utility.NewError(utility.KindCopy, err)
+	}
+	pluginsDir, err = filepath.EvalSymlinks(pluginsDir)
+	if err != nil {
+		return utility.NewError(utility.KindCopy, err)
+	}
+	stagingDir, err := ioutil.TempDir(pluginsDir, ".install-")
+	if err != nil {
+		fmt.Printf("Error creating a staging directory: %v\n", err.Error())
+		return utility.NewError(utility.KindCopy, err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	archive, err := fetchPackage(pkg, stagingDir)
+	if err != nil {
+		return err
+	}
+	unpackDir := filepath.Join(stagingDir, "plugin")
+	fmt.Printf("Unpacking %v\n", pkg)
+	err = utility.ExtractArchive(archive, unpackDir)
+	if err != nil {
+		fmt.Printf("Error unpacking plugin package: %v\n", err.Error())
+		return utility.NewError(utility.KindUnpack, err)
+	}
+
+	manifest, err := packageManifest(unpackDir)
+	if err != nil {
+		fmt.Println(err.Error())
+		return utility.NewError(utility.KindUnpack, err)
+	}
+
+	pluginPath := filepath.Join(pluginsDir, manifest.Id)
+	_, err = os.Stat(pluginPath)
+	installed := err == nil
+	if installed && !c.Bool(flag_force) {
+		errStr := fmt.Sprintf("Plugin %v is already installed in %v. Use --force to replace it.", manifest.Id, siteDir)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	// keep the installed plugin until the new one is in place
+	if installed {
+		err = os.Rename(pluginPath, filepath.Join(stagingDir, "old"))
+		if err != nil {
+			fmt.Printf("Error moving the installed plugin aside: %v\n", err.Error())
+			return utility.NewError(utility.KindCopy, err)
+		}
+	}
+	err = os.Rename(unpackDir, pluginPath)
+	if err != nil {
+		fmt.Printf("Error moving plugin into place: %v\n", err.Error())
+		if installed {
+			_ = os.Rename(filepath.Join(stagingDir, "old"), pluginPath)
+		}
+		return utility.NewError(utility.KindCopy, err)
+	}
+
+	fmt.Printf("Installed plugin %v %v into %v. Restart gocms to load it.\n", manifest.Id, manifest.Version, pluginPath)
+	utility.SetResult("directory", siteDir)
+	utility.SetResult("plugin", manifest.Id)
+	utility.SetResult("version", manifest.Version)
+	utility.SetResult("replaced", installed)
+
+	return nil
+}
+
+// fetchPackage returns the path of the package archive, downloading it into dir first when it is a url.
+func fetchPackage(pkg string, dir string) (string, error) {
+	if !strings.HasPrefix(pkg, "http://") && !strings.HasPrefix(pkg, "https://") {
+		if _, err := os.Stat(pkg); err != nil {
+			errStr := fmt.Sprintf("Can't read plugin package %v: %v", pkg, err.Error())
+			fmt.Println(errStr)
+			return "", utility.NewError(utility.KindUsage, errors.New(errStr))
+		}
+		return pkg, nil
+	}
+
+	checksum, err := utility.FetchChecksum(pkg)
+	if err != nil {
+		fmt.Printf("Error fetching the checksum of the plugin package: %v\n", err.Error())
+		return "", err
+	}
+	if checksum == "" {
+		fmt.Printf("No checksum is published for %v. It can't be verified.\n", pkg)
+	}
+	archive := filepath.Join(dir, path.Base(pkg))
+	fmt.Printf("Downloading: %v...\n", pkg)
+	err = utility.FetchFile(archive, pkg, checksum)
+	if err != nil {
+		fmt.Printf("Error downloading plugin package: %v\n", err.Error())
+		return "", utility.NewError(utility.KindDownload, err)
+	}
+	return archive, nil
+}
+
+// packageManifest reads and checks the manifest at the root of an unpacked plugin package.
+func packageManifest(dir string) (*models.PluginManifest, error) {
+	manifestPath := filepath.Join(dir, config.PLUGIN_MANIFEST)
+	if _, err := os.Stat(manifestPath); err != nil {
+		return nil, fmt.Errorf("The package has no %v at its root. Is it a plugin package?", config.PLUGIN_MANIFEST)
+	}
+	manifest, err := utility.ParseManifest(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the package's %v: %v", config.PLUGIN_MANIFEST, err.Error())
+	}
+
+	// the id names the plugin's directory
+	if manifest.Id == "" || manifest.Id != filepath.Base(manifest.Id) || manifest.Id == "." || manifest.Id == ".." || strings.ContainsAny(manifest.Id, "/\\") {
+		return nil, fmt.Errorf("The package's manifest has an invalid id '%v'.", manifest.Id)
+	}
+	if manifest.Services.Bin != "" {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(manifest.Services.Bin))); err != nil {
+			return nil, fmt.Errorf("The package doesn't hold the plugin binary %v named in its manifest.", manifest.Services.Bin)
+		}
+	}
+	return manifest, nil
+}
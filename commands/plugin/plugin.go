@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	"github.com/urfave/cli"
+)
+
+var CMD_PLUGIN = cli.Command{
+	Name:  "plugin",
+	Usage: "Tools for working with gocms plugins",
+	Subcommands: []cli.Command{
+		CMD_INSTALL,
+		CMD_TEST_ROUTES,
+	},
+}
@@ -0,0 +1,348 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/models"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const flag_url = "url"
+const flag_junit = "junit"
+const flag_wait = "wait"
+
+var routeParamRegex = regexp.MustCompile(`:(\w+)|\{(\w+)\}|\*(\w+)`)
+
+var CMD_TEST_ROUTES = cli.Command{
+	Name:      "test-routes",
+	Usage:     "Call every route declared in a plugin manifest against gocms and check the responses. Expectations are read from routetests/<route name>.json beside the manifest. Without one a route passes unless it returns 404 or a server error.",
+	ArgsUsage: "<source> <gocms installation>",
+	Action:    cmd_test_routes,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  flag_url,
+			Usage: "Url of gocms. Defaults to localhost and the port from the installation's .env file. gocms is started if it isn't running.",
+		},
+		cli.StringFlag{
+			Name:  flag_junit,
+			Usage: "Write the results as a JUnit XML report to this file.",
+		},
+		cli.DurationFlag{
+			Name:  flag_wait,
+			Value: 30 * time.Second,
+			Usage: "How long to wait for gocms to become ready.",
+		},
+	},
+}
+
+type routeTestContext struct {
+	srcDir     string
+	installDir string
+	goCMSUrl   string
+	verbose    bool
+	manifest   *models.PluginManifest
+	client     *http.Client
+}
+
+type routeTestResult struct {
+	route    *models.PluginManifestRoute
+	method   string
+	url      string
+	status   int
+	duration time.Duration
+	failure  string
+	skipped  string
+	body     []byte
+}
+
+func cmd_test_routes(c *cli.Context) error {
+
+	args, err := sites.Args(c, 1)
+	if err != nil {
+		return err
+	}
+	srcDir := args.Get(0)
+	installDir := args.Get(1)
+	if srcDir == "" || installDir == "" {
+		errStr := "A source and destination directory must be specified."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	rctx := routeTestContext{
+		srcDir:     filepath.Clean(srcDir),
+		installDir: filepath.Clean(installDir),
+		goCMSUrl:   c.String(flag_url),
+		verbose:    c.GlobalBool(config.FLAG_VERBOSE),
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+	if rctx.goCMSUrl == "" {
+		rctx.goCMSUrl = utility.GoCMSUrl(rctx.installDir)
+	}
+
+	// parse manifest file
+	manifestPath := filepath.Join(rctx.srcDir, config.PLUGIN_MANIFEST)
+	manifest, err := utility.ParseManifest(manifestPath)
+	if err != nil {
+		fmt.Printf("Error parsing manifest file %v: %v\n", manifestPath, err.Error())
+		return err
+	}
+	rctx.manifest = manifest
+
+	// use a running gocms or start one for the tests
+	stopGoCMS, err := rctx.ensureGoCMS(c.Duration(flag_wait))
+	if err != nil {
+		return err
+	}
+	defer stopGoCMS()
+
+	start := time.Now()
+	suite := utility.NewJUnitTestSuite(manifest.Id, start)
+	var results []*routeTestResult
+	for _, route := range manifest.Services.Routes {
+		result := rctx.testRoute(route)
+		results = append(results, result)
+		suite.AddCase(result.junitCase(manifest.Id))
+	}
+	suite.Time = utility.JUnitDuration(time.Since(start))
+
+	printRouteTestResults(results)
+
+	if c.String(flag_junit) != "" {
+		err = utility.WriteJUnitReport(c.String(flag_junit), []*utility.JUnitTestSuite{suite}, time.Since(start))
+		if err != nil {
+			fmt.Printf("Error writing JUnit report: %v\n", err.Error())
+			return err
+		}
+		fmt.Printf("JUnit report written to %v\n", c.String(flag_junit))
+	}
+
+	if suite.Failures > 0 {
+		errStr := fmt.Sprintf("%v of %v routes failed.", suite.Failures, suite.Tests)
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+
+	fmt.Printf("%v routes passed, %v skipped.\n", suite.Tests-suite.Skipped, suite.Skipped)
+	return nil
+}
+
+// ensureGoCMS starts gocms unless it is already answering. The returned func stops it again if it was started here.
+func (rctx *routeTestContext) ensureGoCMS(wait time.Duration) (func(), error) {
+	if utility.WaitForReady(context.Background(), rctx.goCMSUrl, time.Second) == nil {
+		fmt.Printf("Using running GoCMS at %v\n", rctx.goCMSUrl)
+		return func() {}, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(utility.ActiveInstallDir(rctx.installDir), config.BINARY_FILE)); os.IsNotExist(err) {
+		errStr := "The provided directory doesn't appear to be an active GoCMS installation."
+		fmt.Println(errStr)
+		return nil, utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	options := utility.GoCMSOptions{}
+	if !rctx.verbose {
+		options.Stdout = ioutil.Discard
+		options.Stderr = ioutil.Discard
+	}
+	goCMS, err := utility.NewGoCMSSupervisor(rctx.installDir, options)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Starting GoCMS in %v\n", rctx.installDir)
+	err = goCMS.Start()
+	if err != nil {
+		fmt.Printf("Error starting gocms: %v\n", err.Error())
+		return nil, err
+	}
+
+	err = utility.WaitForReady(context.Background(), rctx.goCMSUrl, wait)
+	if err != nil {
+		goCMS.Stop()
+		fmt.Printf("GoCMS is not ready: %v\n", err.Error())
+		return nil, err
+	}
+
+	return goCMS.Stop, nil
+}
+
+func (rctx *routeTestContext) testRoute(route *models.PluginManifestRoute) *routeTestResult {
+	result := &routeTestResult{
+		route:  route,
+		method: strings.ToUpper(route.Method),
+	}
+	if result.method == "" {
+		result.method = http.MethodGet
+	}
+
+	fixture, err := rctx.loadFixture(route)
+	if err != nil {
+		result.failure = err.Error()
+		return result
+	}
+
+	if route.Url == "" {
+		result.skipped = "no url declared"
+		return result
+	}
+
+	// fill in route parameters
+	path := route.Url
+	var missing []string
+	path = routeParamRegex.ReplaceAllStringFunc(path, func(param string) string {
+		name := strings.Trim(param, ":{}*")
+		if value, ok := fixture.Params[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return param
+	})
+	result.url = utility.RouteUrl(rctx.goCMSUrl, path)
+	if len(missing) > 0 {
+		result.skipped = fmt.Sprintf("no fixture value for %v", strings.Join(missing, ", "))
+		return result
+	}
+
+	var body io.Reader
+	if len(fixture.Body) > 0 {
+		body = bytes.NewReader(fixture.Body)
+	}
+	req, err := http.NewRequest(result.method, result.url, body)
+	if err != nil {
+		result.failure = err.Error()
+		return result
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range fixture.Headers {
+		req.Header.Set(key, value)
+	}
+
+	start := time.Now()
+	resp, err := rctx.client.Do(req)
+	result.duration = time.Since(start)
+	if err != nil {
+		result.failure = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	result.status = resp.StatusCode
+	result.body, _ = ioutil.ReadAll(resp.Body)
+
+	result.failure = checkExpectation(fixture.Expect, result)
+	return result
+}
+
+// loadFixture reads the fixture for a route. Routes without one get an empty fixture.
+func (rctx *routeTestContext) loadFixture(route *models.PluginManifestRoute) (*models.RouteFixture, error) {
+	fixture := &models.RouteFixture{}
+
+	fixturePath := filepath.Join(rctx.srcDir, config.ROUTE_TESTS_DIR, route.Name+".json")
+	raw, err := ioutil.ReadFile(fixturePath)
+	if os.IsNotExist(err) {
+		return fixture, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, fixture)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixture %v: %v", fixturePath, err.Error())
+	}
+
+	return fixture, nil
+}
+
+func checkExpectation(expect models.RouteExpectation, result *routeTestResult) string {
+
+	// status
+	if expect.Status != 0 {
+		if result.status != expect.Status {
+			return fmt.Sprintf("expected status %v but got %v", expect.Status, result.status)
+		}
+	} else if result.status == http.StatusNotFound || result.status >= 500 {
+		return fmt.Sprintf("unexpected status %v", result.status)
+	}
+
+	// exact json body
+	if len(expect.Body) > 0 {
+		var expected, actual interface{}
+		err := json.Unmarshal(expect.Body, &expected)
+		if err != nil {
+			return fmt.Sprintf("invalid expected body: %v", err.Error())
+		}
+		err = json.Unmarshal(result.body, &actual)
+		if err != nil {
+			return fmt.Sprintf("response isn't json: %v", err.Error())
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			return fmt.Sprintf("expected body %s but got %s", expect.Body, result.body)
+		}
+	}
+
+	// partial body
+	if expect.Contains != "" && !strings.Contains(string(result.body), expect.Contains) {
+		return fmt.Sprintf("expected body to contain '%v'", expect.Contains)
+	}
+
+	return ""
+}
+
+func (result *routeTestResult) junitCase(pluginId string) *utility.JUnitTestCase {
+	name := result.route.Name
+	if name == "" {
+		name = result.method + " " + result.route.Url
+	}
+
+	tc := &utility.JUnitTestCase{
+		Name:      name,
+		ClassName: "plugin." + pluginId,
+		Time:      utility.JUnitDuration(result.duration),
+	}
+	switch {
+	case result.skipped != "":
+		tc.Skipped = &utility.JUnitMessage{Message: result.skipped}
+	case result.failure != "":
+		tc.Failure = &utility.JUnitMessage{
+			Message: result.failure,
+			Text:    fmt.Sprintf("%v %v\nstatus: %v\n%s", result.method, result.url, result.status, result.body),
+		}
+	}
+
+	return tc
+}
+
+func printRouteTestResults(results []*routeTestResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE\tMETHOD\tURL\tSTATUS\tRESULT")
+	for _, result := range results {
+		outcome := "ok"
+		switch {
+		case result.skipped != "":
+			outcome = "skipped: " + result.skipped
+		case result.failure != "":
+			outcome = "FAILED: " + result.failure
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", result.route.Name, result.method, result.url, result.status, outcome)
+	}
+	w.Flush()
+}
@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTestRoute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/api/items/42" {
+			w.Write([]byte(`{"id":42}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	srcDir, err := ioutil.TempDir("", "gcm-routes-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srcDir)
+	err = os.MkdirAll(filepath.Join(srcDir, config.ROUTE_TESTS_DIR), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := `{"params": {"id": "42"}, "expect": {"status": 200, "body": {"id": 42}}}`
+	for _, name := range []string{"item", "wrong"} {
+		err = ioutil.WriteFile(filepath.Join(srcDir, config.ROUTE_TESTS_DIR, name+".json"), []byte(fixture), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rctx := &routeTestContext{srcDir: srcDir, goCMSUrl: server.URL, client: server.Client()}
+	tests := []struct {
+		name     string
+		route    *models.PluginManifestRoute
+		url      string
+		caseName string
+		failed   bool
+		skipped  bool
+	}{
+		{
+			name:     "url not route",
+			route:    &models.PluginManifestRoute{Name: "item", Route: "public", Method: "GET", Url: "/api/items/:id"},
+			url:      server.URL + "/api/items/42",
+			caseName: "item",
+		},
+		{
+			name:     "route isn't requested",
+			route:    &models.PluginManifestRoute{Name: "wrong", Route: "/api/items/:id", Method: "GET", Url: "/api/other/:id"},
+			url:      server.URL + "/api/other/42",
+			caseName: "wrong",
+			failed:   true,
+		},
+		{
+			name:     "unnamed routes are named by their url",
+			route:    &models.PluginManifestRoute{Route: "public", Method: "GET", Url: "/api/items/:id"},
+			url:      server.URL + "/api/items/:id",
+			caseName: "GET /api/items/:id",
+			skipped:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := rctx.testRoute(test.route)
+			if result.url != test.url {
+				t.Errorf("requested %v, expected %v", result.url, test.url)
+			}
+			if (result.failure != "") != test.failed {
+				t.Errorf("failure %q, expected failed %v", result.failure, test.failed)
+			}
+			if (result.skipped != "") != test.skipped {
+				t.Errorf("skipped %q, expected skipped %v", result.skipped, test.skipped)
+			}
+			if tc := result.junitCase("test"); tc.Name != test.caseName {
+				t.Errorf("test case is named %q, expected %q", tc.Name, test.caseName)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package releases
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+var CMD_RELEASES = cli.Command{
+	Name:  "releases",
+	Usage: "Manage the releases of an installation made with install --releases.",
+	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List installed releases, oldest first.",
+			ArgsUsage: "<directory>",
+			Action:    cmd_releases_list,
+		},
+		{
+			Name:      "rollback",
+			Usage:     "Switch back to the previous release, or to the one given. Restart gocms to run it.",
+			ArgsUsage: "<directory> [release]",
+			Action:    cmd_releases_rollback,
+		},
+	},
+}
+
+type releaseInfo struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
+
+func cmd_releases_list(c *cli.Context) error {
+	args, err := sites.Args(c, 0)
+	if err != nil {
+		return err
+	}
+	siteDir, err := siteDirFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	names, err := utility.ListReleases(siteDir)
+	if err != nil {
+		fmt.Printf("Error listing releases: %v\n", err.Error())
+		return utility.NewError(utility.KindInvalidInstall, err)
+	}
+	current, _ := utility.CurrentRelease(siteDir)
+
+	var releases []releaseInfo
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RELEASE\tCURRENT")
+	for _, name := range names {
+		releases = append(releases, releaseInfo{Name: name, Current: name == current})
+		marker := ""
+		if name == current {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%v\t%v\n", name, marker)
+	}
+	w.Flush()
+
+	utility.SetResult("directory", siteDir)
+	utility.SetResult("releases", releases)
+	return nil
+}
+
+func cmd_releases_rollback(c *cli.Context) error {
+	args, err := sites.Args(c, 0)
+	if err != nil {
+		return err
+	}
+	siteDir, err := siteDirFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	current, err := utility.CurrentRelease(siteDir)
+	if err != nil {
+		fmt.Printf("Error reading current release: %v\n", err.Error())
+		return utility.NewError(utility.KindInvalidInstall, err)
+	}
+
+	release := args.Get(1)
+	if release == "" {
+		release, err = utility.PreviousRelease(siteDir)
+		if err != nil {
+			fmt.Printf("Can't roll back: %v\n", err.Error())
+			return utility.NewError(utility.KindRollback, err)
+		}
+	}
+
+	err = utility.SwitchRelease(siteDir, release)
+	if err != nil {
+		fmt.Printf("Error switching to release %v: %v\n", release, err.Error())
+		return utility.NewError(utility.KindRollback, err)
+	}
+
+	fmt.Printf("Switched %v from %v to %v\n", config.CURRENT_RELEASE_LINK, current, release)
+	utility.SetResult("directory", siteDir)
+	utility.SetResult("release", release)
+	utility.SetResult("previous", current)
+	return nil
+}
+
+func siteDirFromArgs(args cli.Args) (string, error) {
+	if !args.Present() {
+		errStr := "An install directory must be specified."
+		fmt.Println(errStr)
+		return "", utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	siteDir := filepath.Clean(args.First())
+	if !utility.IsReleaseLayout(siteDir) {
+		errStr := fmt.Sprintf("%v wasn't installed with --releases.", siteDir)
+		fmt.Println(errStr)
+		return "", utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	return siteDir, nil
+}
@@ -0,0 +1,115 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/urfave/cli"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const flag_follow = "follow"
+const flag_follow_short = "f"
+const flag_lines = "lines"
+const flag_lines_short = "n"
+
+var CMD_LOGS = cli.Command{
+	Name:      "logs",
+	Usage:     "Show the log of a gocms started with 'gcm run --log'.",
+	ArgsUsage: "<directory>",
+	Action:    cmd_logs,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  flag_follow + ", " + flag_follow_short,
+			Usage: "Keep printing new lines as they are written.",
+		},
+		cli.IntFlag{
+			Name:  flag_lines + ", " + flag_lines_short,
+			Value: 50,
+			Usage: "Number of lines to show. 0 shows the whole log.",
+		},
+		logFileFlag,
+	},
+}
+
+func cmd_logs(c *cli.Context) error {
+
+	installDir, err := installDirFromArgs(c)
+	if err != nil {
+		return err
+	}
+
+	logFile := logFilePath(c, installDir)
+	raw, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		fmt.Printf("Error reading log file %v: %v\n", logFile, err.Error())
+		return err
+	}
+
+	os.Stdout.Write(lastLines(raw, c.Int(flag_lines)))
+
+	if c.Bool(flag_follow) {
+		return followLog(logFile, int64(len(raw)))
+	}
+
+	return nil
+}
+
+func lastLines(raw []byte, n int) []byte {
+	if n <= 0 {
+		return raw
+	}
+
+	end := len(raw)
+	if end > 0 && raw[end-1] == '\n' {
+		end--
+	}
+	for i := 0; i < n; i++ {
+		idx := bytes.LastIndexByte(raw[:end], '\n')
+		if idx < 0 {
+			return raw
+		}
+		end = idx
+	}
+
+	return raw[end+1:]
+}
+
+// followLog prints whatever is appended to the log, starting over when it has been rotated.
+func followLog(logFile string, offset int64) error {
+	followed, _ := os.Stat(logFile)
+	for {
+		time.Sleep(500 * time.Millisecond)
+
+		info, err := os.Stat(logFile)
+		if err != nil {
+			continue
+		}
+
+		// rotated. the new file may already have grown past the old offset
+		if info.Size() < offset || followed != nil && !os.SameFile(followed, info) {
+			offset = 0
+		}
+		followed = info
+		if info.Size() == offset {
+			continue
+		}
+
+		f, err := os.Open(logFile)
+		if err != nil {
+			continue
+		}
+		_, err = f.Seek(offset, io.SeekStart)
+		if err == nil {
+			var n int64
+			n, err = io.Copy(os.Stdout, f)
+			offset += n
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package run
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gcm/utility/utility_os"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+)
+
+var CMD_RESTART = cli.Command{
+	Name:      "restart",
+	Usage:     "Restart a gocms started with 'gcm run'.",
+	ArgsUsage: "<directory>",
+	Action:    cmd_restart,
+	Flags: []cli.Flag{
+		pidFileFlag,
+	},
+}
+
+func cmd_restart(c *cli.Context) error {
+
+	installDir, err := installDirFromArgs(c)
+	if err != nil {
+		return err
+	}
+
+	pid, running := utility.RunningPid(pidFilePath(c, installDir))
+	if !running {
+		errStr := "GoCMS isn't running. Start it with 'gcm run'."
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+
+	err = utility_os.Restart_pid(pid)
+	if err != nil {
+		fmt.Printf("Error restarting gocms: %v\n", err.Error())
+		return err
+	}
+
+	fmt.Printf("Restart requested for GoCMS (pid %v).\n", pid)
+	return nil
+}
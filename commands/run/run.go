@@ -0,0 +1,211 @@
+package run
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const flag_restart = "restart"
+const flag_shutdown_timeout = "shutdownTimeout"
+const flag_env = "env"
+const flag_env_short = "e"
+const flag_log = "log"
+const flag_log_short = "l"
+const flag_log_file = "logFile"
+const flag_log_max_size = "logMaxSize"
+const flag_log_max_backups = "logMaxBackups"
+const flag_pid_file = "pidFile"
+
+var pidFileFlag = cli.StringFlag{
+	Name:  flag_pid_file,
+	Usage: "Pid file holding the pid of the gcm supervising gocms, not of gocms itself. Defaults to gocms.pid in the installation.",
+}
+
+var logFileFlag = cli.StringFlag{
+	Name:  flag_log_file,
+	Usage: "Log file to use instead of logs/gocms.log in the installation.",
+}
+
+var CMD_RUN = cli.Command{
+	Name:      "run",
+	Usage:     "Run an installed gocms until interrupted.",
+	ArgsUsage: "<directory>",
+	Action:    cmd_run,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  flag_restart,
+			Value: string(utility.RestartOnFailure),
+			Usage: "When to restart gocms after it exits: no, on-failure or always.",
+		},
+		cli.DurationFlag{
+			Name:  flag_shutdown_timeout,
+			Value: 10 * time.Second,
+			Usage: "How long to wait for gocms to shut down before killing it.",
+		},
+		cli.StringSliceFlag{
+			Name:  flag_env + ", " + flag_env_short,
+			Usage: "Environment variable for gocms as KEY=VALUE. Overrides the .env file. Accepts multiple instances of the flag.",
+		},
+		cli.BoolFlag{
+			Name:  flag_log + ", " + flag_log_short,
+			Usage: "Also write gocms output to logs/gocms.log in the installation.",
+		},
+		logFileFlag,
+		cli.IntFlag{
+			Name:  flag_log_max_size,
+			Value: 10,
+			Usage: "Rotate the log file once it reaches this many megabytes.",
+		},
+		cli.IntFlag{
+			Name:  flag_log_max_backups,
+			Value: 5,
+			Usage: "Number of rotated log files to keep.",
+		},
+		pidFileFlag,
+	},
+}
+
+func cmd_run(c *cli.Context) error {
+
+	installDir, err := installDirFromArgs(c)
+	if err != nil {
+		return err
+	}
+
+	restartPolicy, err := utility.ParseRestartPolicy(c.String(flag_restart))
+	if err != nil {
+		fmt.Println(err.Error())
+		return utility.NewError(utility.KindUsage, err)
+	}
+
+	// env overrides
+	for _, env := range c.StringSlice(flag_env) {
+		if kv := strings.SplitN(env, "=", 2); len(kv) != 2 || kv[0] == "" {
+			errStr := fmt.Sprintf("Invalid value for --%v: %v. Use KEY=VALUE.", flag_env, env)
+			fmt.Println(errStr)
+			return utility.NewError(utility.KindUsage, errors.New(errStr))
+		}
+	}
+
+	// claim the pid file
+	pidFile := pidFilePath(c, installDir)
+	err = utility.WritePidFile(pidFile)
+	if err != nil {
+		fmt.Printf("Error writing pid file: %v\n", err.Error())
+		return err
+	}
+	defer os.Remove(pidFile)
+
+	options := utility.GoCMSOptions{
+		Env: c.StringSlice(flag_env),
+	}
+
+	// tee output to a rotating log file
+	if c.Bool(flag_log) || c.String(flag_log_file) != "" {
+		logFile, err := utility.NewRotatingFile(logFilePath(c, installDir), int64(c.Int(flag_log_max_size))*1024*1024, c.Int(flag_log_max_backups))
+		if err != nil {
+			fmt.Printf("Error opening log file: %v\n", err.Error())
+			return err
+		}
+		defer logFile.Close()
+		options.Stdout = io.MultiWriter(os.Stdout, logFile)
+		options.Stderr = io.MultiWriter(os.Stderr, logFile)
+		fmt.Printf("Logging to %v\n", logFile.Path)
+	}
+
+	// stop gracefully on interrupt and restart on hangup
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	for {
+		goCMS, err := utility.NewGoCMSSupervisor(installDir, options)
+		if err != nil {
+			return err
+		}
+		goCMS.Restart = restartPolicy
+		goCMS.ShutdownTimeout = c.Duration(flag_shutdown_timeout)
+
+		err = goCMS.Start()
+		if err != nil {
+			fmt.Printf("Error starting gocms: %v\n", err.Error())
+			return err
+		}
+
+		eventsDone := make(chan bool)
+		go func() {
+			utility.PrintProcessEvents(goCMS)
+			close(eventsDone)
+		}()
+
+		restart := false
+		select {
+		case s := <-sig:
+			if s == syscall.SIGHUP {
+				fmt.Printf("Restarting...\n")
+				restart = true
+			} else {
+				fmt.Printf("Quiting...\n")
+			}
+			goCMS.Stop()
+		case <-goCMS.Done():
+		}
+		<-eventsDone
+
+		if restart {
+			continue
+		}
+
+		if goCMS.State() == utility.ProcessFailed {
+			return errors.New("gocms failed")
+		}
+		return nil
+	}
+}
+
+func installDirFromArgs(c *cli.Context) (string, error) {
+	args, err := sites.Args(c, 0)
+	if err != nil {
+		return "", err
+	}
+	if !args.Present() {
+		errStr := "An install directory must be specified."
+		fmt.Println(errStr)
+		return "", utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	installDir := filepath.Clean(args.First())
+
+	// verify this is a gocms dir
+	if _, err := os.Stat(filepath.Join(utility.ActiveInstallDir(installDir), config.BINARY_FILE)); os.IsNotExist(err) {
+		errStr := "The provided directory doesn't appear to be an active GoCMS installation."
+		fmt.Println(errStr)
+		return "", utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	return installDir, nil
+}
+
+func pidFilePath(c *cli.Context, installDir string) string {
+	if c.String(flag_pid_file) != "" {
+		return c.String(flag_pid_file)
+	}
+	return filepath.Join(installDir, config.PID_FILE)
+}
+
+func logFilePath(c *cli.Context, installDir string) string {
+	if c.String(flag_log_file) != "" {
+		return c.String(flag_log_file)
+	}
+	return filepath.Join(installDir, config.LOGS_DIR, config.LOG_FILE)
+}
@@ -0,0 +1,94 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/urfave/cli"
+	"time"
+)
+
+const flag_health_timeout = "healthTimeout"
+
+var CMD_STATUS = cli.Command{
+	Name:      "status",
+	Usage:     "Show whether an installed gocms is running and responding.",
+	ArgsUsage: "<directory>",
+	Action:    cmd_status,
+	Flags: append([]cli.Flag{
+		cli.DurationFlag{
+			Name:  flag_health_timeout,
+			Value: 2 * time.Second,
+			Usage: "How long a running gocms has to respond.",
+		},
+	}, sites.AllFlags...),
+}
+
+func cmd_status(c *cli.Context) error {
+
+	if sites.All(c) {
+		return sites.RunAll(c, statusDetail)
+	}
+
+	installDir, err := installDirFromArgs(c)
+	if err != nil {
+		return err
+	}
+	utility.SetResult("directory", installDir)
+
+	if release, err := utility.CurrentRelease(installDir); err == nil {
+		fmt.Printf("Release: %v\n", release)
+		utility.SetResult("release", release)
+	}
+	if version := utility.InstalledGoCMSVersion(installDir); version != "" {
+		fmt.Printf("Version: %v\n", version)
+		utility.SetResult("version", version)
+	}
+
+	pid, managed, running := utility.FindGoCMS(installDir)
+	utility.SetResult("running", running)
+	if !running {
+		fmt.Println("GoCMS isn't running.")
+		return nil
+	}
+	utility.SetResult("pid", pid)
+	utility.SetResult("managed", managed)
+	if managed {
+		fmt.Printf("GoCMS is running under gcm (pid %v)\n", pid)
+	} else {
+		fmt.Printf("GoCMS is running (pid %v)\n", pid)
+	}
+
+	goCMSUrl := utility.GoCMSUrl(installDir)
+	utility.SetResult("url", goCMSUrl)
+	err = utility.WaitForReady(context.Background(), goCMSUrl, c.Duration(flag_health_timeout))
+	utility.SetResult("healthy", err == nil)
+	if err != nil {
+		fmt.Printf("GoCMS isn't responding at %v: %v\n", goCMSUrl, err.Error())
+		return nil
+	}
+	fmt.Printf("GoCMS is responding at %v\n", goCMSUrl)
+
+	return nil
+}
+
+// statusDetail sums up the status of one site for the table printed by status --all.
+func statusDetail(result map[string]interface{}) string {
+	detail := "stopped"
+	if result["running"] == true {
+		detail = fmt.Sprintf("running (pid %v)", result["pid"])
+		if result["healthy"] == true {
+			detail += ", responding"
+		} else {
+			detail += ", not responding"
+		}
+	}
+	if release, ok := result["release"]; ok {
+		detail += fmt.Sprintf(", release %v", release)
+	}
+	if version, ok := result["version"]; ok {
+		detail += fmt.Sprintf(", version %v", version)
+	}
+	return detail
+}
@@ -0,0 +1,52 @@
+package run
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"time"
+)
+
+const flag_timeout = "timeout"
+
+var CMD_STOP = cli.Command{
+	Name:      "stop",
+	Usage:     "Stop a gocms started with 'gcm run'.",
+	ArgsUsage: "<directory>",
+	Action:    cmd_stop,
+	Flags: []cli.Flag{
+		cli.DurationFlag{
+			Name:  flag_timeout,
+			Value: 30 * time.Second,
+			Usage: "How long to wait for gocms to stop.",
+		},
+		pidFileFlag,
+	},
+}
+
+func cmd_stop(c *cli.Context) error {
+
+	installDir, err := installDirFromArgs(c)
+	if err != nil {
+		return err
+	}
+
+	pid, running := utility.RunningPid(pidFilePath(c, installDir))
+	if !running {
+		fmt.Println("GoCMS isn't running.")
+		return nil
+	}
+
+	// wait for gcm to shut gocms down and exit
+	fmt.Printf("Stopping GoCMS (pid %v)...\n", pid)
+	err = utility.StopPid(pid, c.Duration(flag_timeout))
+	if err != nil {
+		errStr := fmt.Sprintf("Error stopping gocms: %v", err.Error())
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+
+	fmt.Println("GoCMS stopped.")
+	return nil
+}
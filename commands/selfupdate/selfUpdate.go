@@ -0,0 +1,283 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/models"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const flag_check = "check"
+const flag_force = "force"
+const flag_rollback = "rollback"
+
+var versionOutputRegex = regexp.MustCompile(`\bversion (\S+)`)
+
+var CMD_SELF_UPDATE = cli.Command{
+	Name:  "self-update",
+	Usage: "Update gcm to the newest release for this os. The replaced gcm is kept next to the new one as <gcm>.old.",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  flag_check,
+			Usage: "Only report whether a newer gcm is available.",
+		},
+		cli.BoolFlag{
+			Name:  flag_force,
+			Usage: "Install the newest release even if it isn't newer than this gcm.",
+		},
+		cli.BoolFlag{
+			Name:  flag_rollback,
+			Usage: "Swap back to the gcm replaced by the last self-update.",
+		},
+	},
+	Action: cmd_self_update,
+}
+
+func cmd_self_update(c *cli.Context) error {
+	exe, err := executablePath()
+	if err != nil {
+		fmt.Printf("Error finding the running gcm: %v\n", err.Error())
+		return utility.NewError(utility.KindGeneral, err)
+	}
+
+	if c.Bool(flag_rollback) {
+		return rollback(exe)
+	}
+
+	index, err := fetchIndex()
+	if err != nil {
+		return err
+	}
+	utility.SetResult("current", config.GCM_VERSION)
+	utility.SetResult("latest", index.Version)
+
+	build, ok := index.Builds[config.BINARY_OS_PATH]
+	if !ok || build == nil || build.Url == "" {
+		errStr := fmt.Sprintf("There is no gcm %v build for %v.", index.Version, config.BINARY_OS_PATH)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindDownload, errors.New(errStr))
+	}
+
+	newer := utility.CompareVersions(index.Version, config.GCM_VERSION) > 0
+	utility.SetResult("available", newer)
+	if !newer && !c.Bool(flag_force) {
+		fmt.Printf("gcm %v is up to date.\n", config.GCM_VERSION)
+		return nil
+	}
+	if c.Bool(flag_check) {
+		if newer {
+			fmt.Printf("gcm %v is available. This is gcm %v. Run 'gcm self-update' to update.\n", index.Version, config.GCM_VERSION)
+		}
+		return nil
+	}
+
+	// never run a binary we can't verify
+	if build.Sha256 == "" {
+		errStr := fmt.Sprintf("The gcm index doesn't give a sha256 for the %v build. Not updating.", config.BINARY_OS_PATH)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindChecksum, errors.New(errStr))
+	}
+
+	// stage next to the running gcm so it can be renamed into place
+	stageDir, err := ioutil.TempDir(filepath.Dir(exe), ".gcm-update-")
+	if err != nil {
+		fmt.Printf("Error staging the update next to %v: %v\n", exe, err.Error())
+		return utility.NewError(utility.KindCopy, err)
+	}
+	defer os.RemoveAll(stageDir)
+
+	newExe, err := fetchBuild(stageDir, build)
+	if err != nil {
+		return err
+	}
+
+	err = checkBuild(newExe, index.Version)
+	if err != nil {
+		errStr := fmt.Sprintf("The downloaded gcm doesn't work: %v. Not updating.", err.Error())
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	err = replaceExecutable(exe, newExe)
+	if err != nil {
+		fmt.Printf("Error replacing %v: %v\n", exe, err.Error())
+		return utility.NewError(utility.KindCopy, err)
+	}
+
+	fmt.Printf("Updated gcm from %v to %v. The old gcm is kept as %v. Run 'gcm self-update --rollback' to go back to it.\n", config.GCM_VERSION, index.Version, exe+".old")
+	utility.SetResult("version", index.Version)
+	utility.SetResult("previous", config.GCM_VERSION)
+	utility.SetResult("executable", exe)
+	return nil
+}
+
+// executablePath is the file the running gcm was started from with any links resolved, so a link on the path
+// keeps pointing at the updated gcm.
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+func fetchIndex() (*models.GcmIndex, error) {
+	req, err := http.NewRequest(http.MethodGet, utility.GcmIndexUrl(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := utility.DownloadRequest(req)
+	if err != nil {
+		fmt.Printf("Error getting the gcm index: %v\n", err.Error())
+		return nil, utility.NewError(utility.KindDownload, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		errStr := fmt.Sprintf("Error getting the gcm index: %v", response.Status)
+		fmt.Println(errStr)
+		return nil, utility.NewError(utility.KindDownload, errors.New(errStr))
+	}
+
+	var index models.GcmIndex
+	err = json.NewDecoder(response.Body).Decode(&index)
+	if err != nil || index.Version == "" {
+		errStr := fmt.Sprintf("Error reading the gcm index from %v", utility.GcmIndexUrl())
+		if err != nil {
+			errStr += ": " + err.Error()
+		}
+		fmt.Println(errStr)
+		return nil, utility.NewError(utility.KindDownload, errors.New(errStr))
+	}
+	return &index, nil
+}
+
+// fetchBuild downloads and verifies the archive for build and unpacks it into stageDir. It returns the path
+// of the gcm it holds.
+func fetchBuild(stageDir string, build *models.GcmBuild) (string, error) {
+	name := "gcm.zip"
+	if u, err := url.Parse(build.Url); err == nil && path.Base(u.Path) != "" {
+		name = path.Base(u.Path)
+	}
+	archive := filepath.Join(stageDir, name)
+
+	fmt.Printf("Downloading: %v...\n", build.Url)
+	err := utility.FetchFile(archive, build.Url, strings.ToLower(build.Sha256))
+	if err != nil {
+		fmt.Printf("Error downloading gcm: %v\n", err.Error())
+		return "", utility.NewError(utility.KindDownload, err)
+	}
+
+	unpacked := filepath.Join(stageDir, "unpacked")
+	err = utility.ExtractArchive(archive, unpacked)
+	if err != nil {
+		fmt.Printf("Error unpacking gcm: %v\n", err.Error())
+		return "", utility.NewError(utility.KindUnpack, err)
+	}
+
+	newExe := filepath.Join(unpacked, config.GCM_BINARY_FILE)
+	info, err := os.Lstat(newExe)
+	if err != nil || !info.Mode().IsRegular() {
+		errStr := fmt.Sprintf("The gcm archive doesn't contain %v.", config.GCM_BINARY_FILE)
+		fmt.Println(errStr)
+		return "", utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+	return newExe, nil
+}
+
+// checkBuild runs the new gcm to make sure it starts on this machine and is the version the index promised.
+func checkBuild(newExe string, version string) error {
+	err := os.Chmod(newExe, 0755)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, newExe, "--version").CombinedOutput()
+	if err != nil {
+		return err
+	}
+	if !reportsVersion(string(out), version) {
+		return fmt.Errorf("it reports %v instead of version %v", strings.TrimSpace(string(out)), version)
+	}
+	return nil
+}
+
+// reportsVersion tells whether out, what 'gcm --version' printed, is for exactly version.
+// ex: GoCMS Manager (gcm) version 0.0.12 (commit 1a2b3c4, built 2017-06-01T12:00:00Z)
+func reportsVersion(out string, version string) bool {
+	match := versionOutputRegex.FindStringSubmatch(out)
+	return match != nil && utility.CompareVersions(match[1], version) == 0
+}
+
+// replaceExecutable moves exe aside to exe.old and newExe into its place. Both are renames within one
+// directory, so exe is never half written, and moving it aside works even while it runs on windows.
+func replaceExecutable(exe string, newExe string) error {
+	if info, err := os.Stat(exe); err == nil {
+		_ = os.Chmod(newExe, info.Mode().Perm())
+	}
+
+	old := exe + ".old"
+	err := os.Remove(old)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(exe, old)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(newExe, exe)
+	if err != nil {
+		if restoreErr := os.Rename(old, exe); restoreErr != nil {
+			return fmt.Errorf("%v. The old gcm couldn't be put back and is at %v", err.Error(), old)
+		}
+		return err
+	}
+	return nil
+}
+
+// rollback swaps exe with the gcm the last self-update replaced. Running it again swaps them back.
+func rollback(exe string) error {
+	old := exe + ".old"
+	if _, err := os.Stat(old); err != nil {
+		errStr := fmt.Sprintf("There is no previous gcm at %v to roll back to.", old)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindRollback, errors.New(errStr))
+	}
+
+	swap := exe + ".rollback"
+	_ = os.Remove(swap)
+	err := os.Rename(exe, swap)
+	if err == nil {
+		err = os.Rename(old, exe)
+		if err != nil {
+			_ = os.Rename(swap, exe)
+		}
+	}
+	if err == nil {
+		err = os.Rename(swap, old)
+	}
+	if err != nil {
+		fmt.Printf("Error rolling back gcm: %v\n", err.Error())
+		return utility.NewError(utility.KindRollback, err)
+	}
+
+	fmt.Printf("Rolled back to the previous gcm. gcm %v is kept as %v.\n", config.GCM_VERSION, old)
+	utility.SetResult("previous", config.GCM_VERSION)
+	utility.SetResult("executable", exe)
+	return nil
+}
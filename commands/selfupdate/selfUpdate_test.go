@@ -0,0 +1,26 @@
+package selfupdate
+
+import "testing"
+
+func TestReportsVersion(t *testing.T) {
+	tests := []struct {
+		out     string
+		version string
+		want    bool
+	}{
+		{"GoCMS Manager (gcm) version 0.0.12 (commit 1a2b3c4, built 2017-06-01T12:00:00Z)\n", "0.0.12", true},
+		{"GoCMS Manager (gcm) version 0.0.1\n", "0.0.1", true},
+		{"GoCMS Manager (gcm) version v0.0.1\n", "0.0.1", true},
+		{"GoCMS Manager (gcm) version 0.0.12\n", "0.0.1", false},
+		{"GoCMS Manager (gcm) version 0.0.1\n", "0.0.12", false},
+		{"GoCMS Manager (gcm) version 0.0.2 (commit 0.0.1)\n", "0.0.1", false},
+		{"0.0.1", "0.0.1", false},
+		{"", "0.0.1", false},
+	}
+
+	for _, test := range tests {
+		if got := reportsVersion(test.out, test.version); got != test.want {
+			t.Errorf("reportsVersion(%q, %q) = %v, want %v", test.out, test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package sites
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const flag_all = "all"
+const flag_parallel = "parallel"
+
+// AllFlags let a command run for every registered site at once.
+var AllFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  flag_all,
+		Usage: "Run for every registered site in parallel and summarize the outcomes. See 'gcm sites'.",
+	},
+	cli.IntFlag{
+		Name:  flag_parallel,
+		Value: 4,
+		Usage: "How many sites to run at once with --all.",
+	},
+}
+
+// SiteOutcome is how a command went for one site when run with --all.
+type SiteOutcome struct {
+	Site     string                 `json:"site"`
+	Ok       bool                   `json:"ok"`
+	Code     string                 `json:"code,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Duration int64                  `json:"durationMs"`
+	Result   map[string]interface{} `json:"result,omitempty"`
+}
+
+// All reports whether the command was asked to run for every site.
+func All(c *cli.Context) bool {
+	return c.Bool(flag_all)
+}
+
+// RunAll runs the command for every registered site. Each site gets its own gcm run with --site so sites
+// can't get in each other's way, and its output is tagged with the site name. detail describes the result
+// of a site that succeeded for the summary table.
+func RunAll(c *cli.Context, detail func(result map[string]interface{}) string) error {
+	if c.GlobalString(config.FLAG_SITE) != "" || c.Args().Present() {
+		errStr := "--all runs for every registered site and can't be given a site or directory."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	registry, err := openRegistry()
+	if err != nil {
+		return err
+	}
+	if len(registry.Sites) == 0 {
+		errStr := "No sites registered. Add them with 'gcm sites add <name> <directory>'."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	gcm, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	parallel := c.Int(flag_parallel)
+	if parallel < 1 {
+		parallel = 1
+	}
+	fmt.Printf("Running %v for %v sites, %v at a time\n", c.Command.Name, len(registry.Sites), parallel)
+
+	mux := utility.NewLogMux(os.Stdout)
+	outcomes := make([]*SiteOutcome, len(registry.Sites))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, site := range registry.Sites {
+		wg.Add(1)
+		go func(i int, site *utility.Site) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			outcomes[i] = runForSite(gcm, site, mux)
+		}(i, site)
+	}
+	wg.Wait()
+
+	failed := printOutcomes(outcomes, detail)
+	utility.SetResult("sites", outcomes)
+	if failed > 0 {
+		errStr := fmt.Sprintf("%v of %v sites failed.", failed, len(outcomes))
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// runForSite runs this gcm again for site with the same arguments. Its json output is read back to tell
+// how it went.
+func runForSite(gcm string, site *utility.Site, mux *utility.LogMux) *SiteOutcome {
+	outcome := &SiteOutcome{Site: site.Name}
+	start := time.Now()
+	defer func() {
+		outcome.Duration = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	}()
+
+	args := append([]string{"--" + config.FLAG_OUTPUT, utility.OutputJSON, "--" + config.FLAG_SITE, site.Name}, siteArgs(os.Args[1:])...)
+	cmd := exec.Command(gcm, args...)
+	stderr := mux.Stream(site.Name, utility.LogError)
+	defer stderr.Close()
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		outcome.Code = utility.KindGeneral.Code()
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			utility.Event
+			Data json.RawMessage `json:"data"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			mux.WriteLine(site.Name, utility.LogInfo, scanner.Text())
+			continue
+		}
+
+		switch event.Type {
+		case utility.EventLog:
+			mux.WriteLine(site.Name, utility.LogInfo, event.Message)
+		case utility.EventError:
+			outcome.Code = event.Code
+			outcome.Error = event.Message
+		case utility.EventResult:
+			var result utility.ResultData
+			if json.Unmarshal(event.Data, &result) == nil {
+				outcome.Ok = result.Ok
+				outcome.Result = result.Result
+			}
+		}
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		outcome.Ok = false
+		if outcome.Error == "" {
+			outcome.Code = utility.KindGeneral.Code()
+			outcome.Error = err.Error()
+		}
+	}
+	return outcome
+}
+
+// siteArgs is args without the flags that only make sense for the gcm running all sites.
+func siteArgs(args []string) []string {
+	withValue := map[string]bool{config.FLAG_OUTPUT: true, config.FLAG_SITE: true, flag_parallel: true}
+
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			kept = append(kept, args[i:]...)
+			break
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			kept = append(kept, arg)
+			continue
+		}
+		hasValue := strings.Contains(name, "=")
+		name = strings.SplitN(name, "=", 2)[0]
+
+		switch {
+		case name == flag_all:
+		case withValue[name] && !hasValue:
+			// skip the value too
+			i++
+		case withValue[name]:
+		default:
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+func printOutcomes(outcomes []*SiteOutcome, detail func(result map[string]interface{}) string) int {
+	failed := 0
+	fmt.Println("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SITE\tRESULT\tTIME\tDETAIL")
+	for _, outcome := range outcomes {
+		result := "ok"
+		details := ""
+		if outcome.Ok {
+			if detail != nil {
+				details = detail(outcome.Result)
+			}
+		} else {
+			failed++
+			result = outcome.Code
+			details = outcome.Error
+		}
+		took := time.Duration(outcome.Duration) * time.Millisecond
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", outcome.Site, result, took, details)
+	}
+	w.Flush()
+	return failed
+}
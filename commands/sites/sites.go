@@ -0,0 +1,187 @@
+package sites
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+var CMD_SITES = cli.Command{
+	Name:  "sites",
+	Usage: "Name gocms installations so any command can be pointed at one with --site <name>. Set GCM_SITES_FILE to move the list.",
+	Subcommands: []cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Register an installation under a name. The directory doesn't have to be installed yet.",
+			ArgsUsage: "<name> <directory>",
+			Action:    cmd_sites_add,
+		},
+		{
+			Name:   "list",
+			Usage:  "List registered installations.",
+			Action: cmd_sites_list,
+		},
+		{
+			Name:      "remove",
+			Usage:     "Forget an installation. Its files are left alone.",
+			ArgsUsage: "<name>",
+			Action:    cmd_sites_remove,
+		},
+	},
+}
+
+type siteInfo struct {
+	*utility.Site
+	Installed bool `json:"installed"`
+}
+
+func cmd_sites_add(c *cli.Context) error {
+	name := c.Args().Get(0)
+	directory := c.Args().Get(1)
+	if name == "" || directory == "" {
+		errStr := "A site name and directory must be specified."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	registry, err := openRegistry()
+	if err != nil {
+		return err
+	}
+
+	site, err := registry.Add(name, directory)
+	if err != nil {
+		errStr := fmt.Sprintf("Error adding site: %v", err.Error())
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	err = registry.Save()
+	if err != nil {
+		fmt.Printf("Error saving %v: %v\n", registry.File, err.Error())
+		return err
+	}
+
+	fmt.Printf("Added site %v for %v\n", site.Name, site.Directory)
+	if !installed(site.Directory) {
+		fmt.Printf("%v isn't a GoCMS installation yet. Install it with 'gcm --site %v install'.\n", site.Directory, site.Name)
+	}
+	utility.SetResult("site", site)
+	return nil
+}
+
+func cmd_sites_list(c *cli.Context) error {
+	registry, err := openRegistry()
+	if err != nil {
+		return err
+	}
+
+	sites := []siteInfo{}
+	for _, site := range registry.Sites {
+		sites = append(sites, siteInfo{Site: site, Installed: installed(site.Directory)})
+	}
+	utility.SetResult("file", registry.File)
+	utility.SetResult("sites", sites)
+
+	if len(sites) == 0 {
+		fmt.Println("No sites registered. Add one with 'gcm sites add <name> <directory>'.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDIRECTORY\tINSTALLED")
+	for _, site := range sites {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", site.Name, site.Directory, site.Installed)
+	}
+	w.Flush()
+	return nil
+}
+
+func cmd_sites_remove(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		errStr := "A site name must be specified."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	registry, err := openRegistry()
+	if err != nil {
+		return err
+	}
+
+	site := registry.Remove(name)
+	if site == nil {
+		errStr := fmt.Sprintf("There is no site named %v.", name)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+
+	err = registry.Save()
+	if err != nil {
+		fmt.Printf("Error saving %v: %v\n", registry.File, err.Error())
+		return err
+	}
+
+	fmt.Printf("Removed site %v. %v was left as it is.\n", site.Name, site.Directory)
+	utility.SetResult("site", site)
+	return nil
+}
+
+// Args returns the arguments of a command whose argument n is an installation directory. With --site the
+// directory of that site is put in as argument n, so any other arguments are given as usual around it.
+func Args(c *cli.Context, n int) (cli.Args, error) {
+	name := c.GlobalString(config.FLAG_SITE)
+	if name == "" {
+		return c.Args(), nil
+	}
+
+	site, err := lookupSite(name)
+	if err != nil {
+		return nil, err
+	}
+
+	args := cli.Args{}
+	for i := 0; i < n; i++ {
+		args = append(args, c.Args().Get(i))
+	}
+	args = append(args, site.Directory)
+	if len(c.Args()) > n {
+		args = append(args, c.Args()[n:]...)
+	}
+	return args, nil
+}
+
+func lookupSite(name string) (*utility.Site, error) {
+	registry, err := openRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	site := registry.Lookup(name)
+	if site == nil {
+		errStr := fmt.Sprintf("There is no site named %v. See 'gcm sites list'.", name)
+		fmt.Println(errStr)
+		return nil, utility.NewError(utility.KindUsage, errors.New(errStr))
+	}
+	return site, nil
+}
+
+func openRegistry() (*utility.SiteRegistry, error) {
+	registry, err := utility.OpenSiteRegistry()
+	if err != nil {
+		fmt.Printf("Error opening the site registry: %v\n", err.Error())
+		return nil, err
+	}
+	return registry, nil
+}
+
+func installed(directory string) bool {
+	_, err := os.Stat(filepath.Join(utility.ActiveInstallDir(directory), config.BINARY_FILE))
+	return err == nil
+}
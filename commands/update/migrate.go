@@ -0,0 +1,227 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/models"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// releaseMigrations runs the migrations a release declares in its release.json. Their output is written
+// to a log file in the installation as well as shown.
+type releaseMigrations struct {
+	manifest   *models.ReleaseManifest
+	siteDir    string
+	releaseDir string
+	backupDir  string
+	logPath    string
+	verbose    bool
+	migrated   bool
+}
+
+// loadMigrations reads the release manifest of the staged release. Releases without one have nothing to migrate.
+func loadMigrations(siteDir string, releaseDir string, verbose bool) (*releaseMigrations, error) {
+	manifestPath := filepath.Join(releaseDir, config.RELEASE_MANIFEST)
+	manifest, err := utility.ParseReleaseManifest(manifestPath)
+	if os.IsNotExist(err) {
+		return &releaseMigrations{}, nil
+	}
+	if err != nil {
+		errStr := fmt.Sprintf("Error reading release manifest %v: %v", manifestPath, err.Error())
+		fmt.Println(errStr)
+		return nil, utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	stamp := time.Now().Format("20060102150405")
+	return &releaseMigrations{
+		manifest:   manifest,
+		siteDir:    siteDir,
+		releaseDir: releaseDir,
+		backupDir:  filepath.Join(siteDir, config.BACKUPS_DIR, stamp),
+		logPath:    filepath.Join(siteDir, config.LOGS_DIR, fmt.Sprintf("migrate-%v.log", stamp)),
+		verbose:    verbose,
+	}, nil
+}
+
+func (m *releaseMigrations) pending() bool {
+	return m.manifest != nil && len(m.manifest.Migrations) > 0
+}
+
+// run takes a backup and then runs every migration in order, stopping at the first that fails.
+func (m *releaseMigrations) run() error {
+	if !m.pending() {
+		return nil
+	}
+
+	fmt.Printf("Running %v migrations. Output is logged to %v\n", len(m.manifest.Migrations), m.logPath)
+	utility.SetResult("migrationLog", m.logPath)
+
+	err := m.backup()
+	if err != nil {
+		errStr := fmt.Sprintf("Error backing up before migrating: %v", err.Error())
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindMigration, errors.New(errStr))
+	}
+
+	// from here on a failure needs undoing
+	m.migrated = true
+
+	var names []string
+	for i, step := range m.manifest.Migrations {
+		name := stepName(step, fmt.Sprintf("#%v", i+1))
+		err = m.runStep("migration", name, step)
+		if err != nil {
+			return utility.NewError(utility.KindMigration, err)
+		}
+		names = append(names, name)
+	}
+	utility.SetResult("migrations", names)
+
+	return nil
+}
+
+// backupPaths are the files of the installation migrations may change: shared/ in the release layout and
+// otherwise .env, plugins and themes.
+func (m *releaseMigrations) backupPaths() []string {
+	if utility.IsReleaseLayout(m.siteDir) {
+		return []string{config.SHARED_DIR}
+	}
+	return []string{
+		config.ENV_FILE,
+		filepath.Join(config.CONTENT_DIR, config.PLUGINS_DIR),
+		filepath.Join(config.CONTENT_DIR, config.THEMES_DIR),
+	}
+}
+
+// backup copies the files migrations run against and runs the release's own backup step.
+func (m *releaseMigrations) backup() error {
+	err := os.MkdirAll(m.backupDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backing up to %v\n", m.backupDir)
+	utility.SetResult("backup", m.backupDir)
+
+	for _, p := range m.backupPaths() {
+		src := filepath.Join(m.siteDir, p)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		err = utility.Copy(src, filepath.Join(m.backupDir, p), false, m.verbose)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.manifest.Backup != nil {
+		return m.runStep("backup", stepName(m.manifest.Backup, "backup"), m.manifest.Backup)
+	}
+	return nil
+}
+
+// undo runs the release's restore step and puts the backed up files back once the update is rolled back.
+// Nothing is done unless migrations were started.
+func (m *releaseMigrations) undo() error {
+	if !m.migrated {
+		return nil
+	}
+
+	fmt.Println("Undoing migrations...")
+	if m.manifest.Restore != nil {
+		err := m.runStep("restore", stepName(m.manifest.Restore, "restore"), m.manifest.Restore)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range m.backupPaths() {
+		backup := filepath.Join(m.backupDir, p)
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			continue
+		}
+		err := utility.Copy(backup, filepath.Join(m.siteDir, p), true, m.verbose)
+		if err != nil {
+			return fmt.Errorf("can't restore %v from %v: %v", p, backup, err.Error())
+		}
+	}
+
+	fmt.Printf("Migrations undone. The backup is kept in %v\n", m.backupDir)
+	return nil
+}
+
+func (m *releaseMigrations) runStep(kind string, name string, step *models.ReleaseStep) error {
+	hook, err := m.stepHook(step)
+	if err != nil {
+		errStr := fmt.Sprintf("%v '%v' is invalid: %v", kind, name, err.Error())
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+
+	err = os.MkdirAll(filepath.Dir(m.logPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(m.logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	fmt.Fprintf(logFile, "==> %v %v: %v (%v)\n", kind, name, hook.Command, time.Now().Format(time.RFC3339))
+	fmt.Printf("Running %v '%v'\n", kind, name)
+	out := io.MultiWriter(os.Stdout, logFile)
+	err = utility.RunHook(context.Background(), hook, m.releaseDir, m.verbose, out, out)
+	if err != nil {
+		fmt.Fprintf(logFile, "==> failed: %v\n", err.Error())
+		errStr := fmt.Sprintf("%v '%v' failed: %v. See %v", kind, name, err.Error(), m.logPath)
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// stepHook turns a step into a hook run in the release directory. Scripts must be part of the release.
+func (m *releaseMigrations) stepHook(step *models.ReleaseStep) (*models.PluginHook, error) {
+	hook := &models.PluginHook{
+		Name:    step.Name,
+		Timeout: step.Timeout,
+		Env: map[string]string{
+			"GCM_SITE_DIR":    m.siteDir,
+			"GCM_RELEASE_DIR": m.releaseDir,
+			"GCM_BACKUP_DIR":  m.backupDir,
+		},
+	}
+	for key, value := range step.Env {
+		hook.Env[key] = value
+	}
+
+	switch {
+	case step.GoCMS != "" && step.Script != "":
+		return nil, errors.New("only one of gocms and script can be given")
+	case step.GoCMS != "":
+		hook.Command = "./" + config.BINARY_FILE + " " + step.GoCMS
+	case step.Script != "":
+		script := filepath.ToSlash(filepath.Clean(step.Script))
+		if filepath.IsAbs(step.Script) || script == ".." || strings.HasPrefix(script, "../") {
+			return nil, fmt.Errorf("script %v isn't inside the release", step.Script)
+		}
+		hook.Command = "./" + script
+	default:
+		return nil, errors.New("neither gocms nor script is given")
+	}
+	return hook, nil
+}
+
+func stepName(step *models.ReleaseStep, fallback string) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return fallback
+}
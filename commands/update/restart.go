@@ -0,0 +1,232 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gcm/utility/utility_os"
+	"github.com/gocms-io/gocms/utility/errors"
+	"github.com/urfave/cli"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const flag_stop = "stop"
+const flag_no_restart = "noRestart"
+const flag_health_url = "healthUrl"
+const flag_health_timeout = "healthTimeout"
+const flag_stop_timeout = "stopTimeout"
+
+var restartFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  flag_stop,
+		Usage: "Stop a running gocms before swapping releases instead of restarting it once the new one is in place. Installations without --releases are always stopped first.",
+	},
+	cli.BoolFlag{
+		Name:  flag_no_restart,
+		Usage: "Leave a running gocms alone. It keeps running the old version until it is restarted.",
+	},
+	cli.StringFlag{
+		Name:  flag_health_url,
+		Usage: "Url that must respond once gocms is restarted. Defaults to the gocms url from .env.",
+	},
+	cli.DurationFlag{
+		Name:  flag_health_timeout,
+		Value: 60 * time.Second,
+		Usage: "How long the new version has to become healthy before the update is rolled back.",
+	},
+	cli.DurationFlag{
+		Name:  flag_stop_timeout,
+		Value: 30 * time.Second,
+		Usage: "How long to wait for gocms to stop.",
+	},
+}
+
+// runningGoCMS coordinates an update with a gocms running in the installation. A gocms that isn't running
+// is left that way.
+type runningGoCMS struct {
+	siteDir       string
+	pid           int
+	managed       bool
+	stopFirst     bool
+	restart       bool
+	healthUrl     string
+	healthTimeout time.Duration
+	stopTimeout   time.Duration
+
+	stopped  bool
+	replaced bool
+	exited   chan struct{}
+}
+
+func findRunningGoCMS(c *cli.Context, siteDir string) *runningGoCMS {
+	g := &runningGoCMS{
+		siteDir:       siteDir,
+		stopFirst:     c.Bool(flag_stop),
+		restart:       !c.Bool(flag_no_restart),
+		healthUrl:     c.String(flag_health_url),
+		healthTimeout: c.Duration(flag_health_timeout),
+		stopTimeout:   c.Duration(flag_stop_timeout),
+	}
+
+	pid, managed, found := utility.FindGoCMS(siteDir)
+	if !found {
+		return g
+	}
+	g.pid = pid
+	g.managed = managed
+
+	if managed {
+		fmt.Printf("GoCMS is running in %v under gcm (pid %v)\n", siteDir, pid)
+	} else {
+		fmt.Printf("GoCMS is running in %v (pid %v)\n", siteDir, pid)
+	}
+	if !g.restart && !g.stopFirst {
+		fmt.Println("GoCMS will keep running the old version until it is restarted.")
+	}
+	utility.SetResult("running", true)
+	return g
+}
+
+func (g *runningGoCMS) running() bool {
+	return g.pid != 0 && (g.restart || g.stopFirst)
+}
+
+// beforeSwap stops gocms if it should be down while files change.
+func (g *runningGoCMS) beforeSwap() error {
+	if !g.running() || !g.stopFirst {
+		return nil
+	}
+	return g.stop()
+}
+
+// afterSwap gets gocms running the new version and waits for it to become healthy.
+func (g *runningGoCMS) afterSwap() error {
+	if !g.running() {
+		return nil
+	}
+	if !g.stopped {
+		err := g.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := g.start()
+	if err != nil {
+		return err
+	}
+	return g.waitHealthy()
+}
+
+// afterRollback gets the old version running again once its files are back in place.
+func (g *runningGoCMS) afterRollback() error {
+	if !g.running() || !g.replaced {
+		return nil
+	}
+
+	// the new version may still be up but unhealthy
+	if !g.stopped {
+		err := g.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Restarting the old version of GoCMS...")
+	err := g.start()
+	if err != nil {
+		return err
+	}
+	return g.waitHealthy()
+}
+
+func (g *runningGoCMS) stop() error {
+	if g.exited != nil {
+		select {
+		case <-g.exited:
+			g.stopped = true
+			return nil
+		default:
+		}
+	}
+
+	fmt.Printf("Stopping GoCMS (pid %v)...\n", g.pid)
+	err := utility.StopPid(g.pid, g.stopTimeout)
+	if err != nil {
+		errStr := fmt.Sprintf("Error stopping gocms: %v", err.Error())
+		fmt.Println(errStr)
+		return errors.New(errStr)
+	}
+	g.stopped = true
+	g.replaced = true
+	return nil
+}
+
+// start runs gocms under 'gcm run' in the background so it outlives the update. Its output goes to the
+// log file of the installation.
+func (g *runningGoCMS) start() error {
+	gcm, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(gcm, "run", "--log", g.siteDir)
+	utility_os.SetChildProcessGroup(cmd)
+	err = cmd.Start()
+	if err != nil {
+		fmt.Printf("Error starting gocms: %v\n", err.Error())
+		return err
+	}
+
+	g.pid = cmd.Process.Pid
+	g.managed = true
+	g.stopped = false
+	exited := make(chan struct{})
+	g.exited = exited
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	fmt.Printf("Started GoCMS under gcm (pid %v). Logging to %v\n", g.pid, config.LOGS_DIR)
+	return nil
+}
+
+func (g *runningGoCMS) waitHealthy() error {
+	healthUrl := g.healthUrl
+	if healthUrl == "" {
+		healthUrl = utility.GoCMSUrl(g.siteDir)
+	}
+	fmt.Printf("Waiting up to %v for %v...\n", g.healthTimeout, healthUrl)
+
+	// give up early if gocms dies
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exited := g.exited
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	err := utility.WaitForReady(ctx, healthUrl, g.healthTimeout)
+	if err != nil {
+		select {
+		case <-exited:
+			err = errors.New("gocms exited before becoming healthy")
+		default:
+		}
+		errStr := fmt.Sprintf("GoCMS didn't become healthy: %v", err.Error())
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindUnhealthy, errors.New(errStr))
+	}
+
+	utility.PrintReadyBanner(healthUrl, time.Since(start))
+	return nil
+}
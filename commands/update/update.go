@@ -3,101 +3,168 @@ package update
 import (
 	"fmt"
 	"github.com/gocms-io/gcm/commands/install"
+	"github.com/gocms-io/gcm/commands/sites"
 	"github.com/gocms-io/gcm/config"
 	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
 	"github.com/urfave/cli"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 )
 
+const flag_keep_releases = "keepReleases"
+
 var CMD_UPDATE = cli.Command{
 	Name:      "update",
-	Usage:     "Update gocms - The .env file will be preserved. Updates apply to the current working directory.",
-	ArgsUsage: "<directory>",
+	Usage:     "Update gocms - The .env file will be preserved. Updates apply to the directory given or else the current working directory.",
+	ArgsUsage: "[directory]",
 	Action:    cmd_update,
+	Flags: append([]cli.Flag{
+		install.ArchiveFlag,
+		install.FromDirFlag,
+		cli.IntFlag{
+			Name:  flag_keep_releases,
+			Value: 5,
+			Usage: "Number of releases to keep when installed with --releases. 0 keeps them all.",
+		},
+	}, append(restartFlags, sites.AllFlags...)...),
 }
 
 type updatePluginContext struct {
-	backupDir    string
-	stagingDir   string
-	installDir   string
-	versionToUse string
-	verbose      bool
+	backupDir  string
+	stagingDir string
+	installDir string
+	source     install.InstallSource
+	verbose    bool
+	goCMS      *runningGoCMS
+	migrations *releaseMigrations
 }
 
 func cmd_update(c *cli.Context) error {
 
-	installDir, _ := filepath.Abs(".")
+	if sites.All(c) {
+		return sites.RunAll(c, updateDetail)
+	}
+
+	args, err := sites.Args(c, 0)
+	if err != nil {
+		return err
+	}
+	installDir := "."
+	if args.Present() {
+		installDir = args.First()
+	}
+	installDir, _ = filepath.Abs(installDir)
 
 	// verify this is a gocms dir
-	if _, err := os.Stat(filepath.Join(installDir, config.BINARY_FILE)); os.IsNotExist(err) {
-		fmt.Println("The provided directory doesn't appear to be an active GoCMS installation.")
-		return nil
+	if _, err := os.Stat(filepath.Join(utility.ActiveInstallDir(installDir), config.BINARY_FILE)); os.IsNotExist(err) {
+		errStr := "The provided directory doesn't appear to be an active GoCMS installation."
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindInvalidInstall, errors.New(errStr))
+	}
+
+	source, err := install.SourceFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	goCMS := findRunningGoCMS(c, installDir)
+
+	if utility.IsReleaseLayout(installDir) {
+		return releaseUpdate(installDir, source, goCMS, c.Int(flag_keep_releases), c.GlobalBool(config.FLAG_VERBOSE))
 	}
 
-	versionToUse := config.BINARY_DEFAULT_VERSION
-	if c.GlobalString(config.FLAG_SET_VERSION) != "" {
-		versionToUse = c.GlobalString(config.FLAG_SET_VERSION)
+	// files are replaced in place so a running gocms has to be stopped for them
+	if goCMS.pid != 0 {
+		if !goCMS.restart {
+			errStr := "GoCMS is running and its files can't be replaced while it runs. Drop --noRestart or install with --releases to update without stopping it."
+			fmt.Println(errStr)
+			return utility.NewError(utility.KindUsage, errors.New(errStr))
+		}
+		goCMS.stopFirst = true
 	}
 
 	uctx := updatePluginContext{
-		backupDir:    filepath.Join(installDir, config.BACKUP_DIR),
-		stagingDir:   filepath.Join(installDir, config.STAGING_DIR),
-		installDir:   installDir,
-		verbose:      c.GlobalBool(config.FLAG_VERBOSE),
-		versionToUse: versionToUse,
+		backupDir:  filepath.Join(installDir, config.BACKUP_DIR),
+		stagingDir: filepath.Join(installDir, config.STAGING_DIR),
+		installDir: installDir,
+		verbose:    c.GlobalBool(config.FLAG_VERBOSE),
+		source:     source,
+		goCMS:      goCMS,
 	}
 
 	// copy current install to backup
-	err := utility.Copy(uctx.installDir, uctx.backupDir, true, uctx.verbose, "\\.bk", ".bk.*")
+	err = utility.Copy(uctx.installDir, uctx.backupDir, true, uctx.verbose, "\\.bk", ".bk.*")
 	if err != nil {
-		return nil
+		fmt.Printf("Error backing up installation: %v\n", err.Error())
+		return utility.NewError(utility.KindCopy, err)
 	}
 
 	// create staging dir
 	err = os.Mkdir(uctx.stagingDir, os.ModePerm)
 	if err != nil {
 		fmt.Printf("Error creating staging directory %v: %v\n", uctx.stagingDir, err.Error())
-		return nil
+		return utility.NewError(utility.KindCopy, err)
 	}
 
 	// do basic install and rollback on error
-	err = install.BasicInstall(uctx.stagingDir, uctx.versionToUse)
+	err = install.BasicInstall(uctx.stagingDir, uctx.source, uctx.verbose)
 	if err != nil {
 		// roll back update
 		fmt.Print("Rolling back changes...\n")
 		os.RemoveAll(uctx.backupDir)
 		os.RemoveAll(uctx.stagingDir)
-		return nil
+		return err
 	}
 
 	// merge backup and staging into installation dir
 	fmt.Print("Applying update to staging...\n")
 
-	// .env
-	err = os.Rename(filepath.Join(uctx.backupDir, config.ENV_FILE), filepath.Join(uctx.stagingDir, config.ENV_FILE))
+	// .env, plugins and themes carry over. They are copied so the backup stays whole for a rollback.
+	err = uctx.keepFromBackup(config.ENV_FILE)
 	if err != nil {
 		fmt.Printf("Error applying .env file: %v\n", err.Error())
-		uctx.rollback()
-		return nil
+		return uctx.rollback(err)
 	}
 
-	// plugins
-	err = utility.ForceRename(filepath.Join(uctx.backupDir, config.CONTENT_DIR, config.PLUGINS_DIR), filepath.Join(uctx.stagingDir, config.CONTENT_DIR, config.PLUGINS_DIR))
+	err = uctx.keepFromBackup(filepath.Join(config.CONTENT_DIR, config.PLUGINS_DIR))
 	if err != nil {
 		fmt.Printf("Error applying plugins file: %v\n", err.Error())
-		uctx.rollback()
-		return nil
+		return uctx.rollback(err)
 	}
 
-	// docs
-	// remove these default dir first
-	_ = os.RemoveAll(filepath.Join(uctx.backupDir, config.CONTENT_DIR, config.THEMES_DIR, config.THEMES_DEFAULT_DIR))
-	err = utility.ForceRename(filepath.Join(uctx.backupDir, config.CONTENT_DIR, config.THEMES_DIR), filepath.Join(uctx.stagingDir, config.CONTENT_DIR, config.THEMES_DIR))
+	// the default theme comes with the new version
+	themes, err := ioutil.ReadDir(filepath.Join(uctx.backupDir, config.CONTENT_DIR, config.THEMES_DIR))
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error reading themes: %v\n", err.Error())
+		return uctx.rollback(err)
+	}
+	for _, theme := range themes {
+		if theme.Name() == config.THEMES_DEFAULT_DIR {
+			continue
+		}
+		err = uctx.keepFromBackup(filepath.Join(config.CONTENT_DIR, config.THEMES_DIR, theme.Name()))
+		if err != nil {
+			fmt.Printf("Error applying themes file: %v\n", err.Error())
+			return uctx.rollback(err)
+		}
+	}
+
+	// stop gocms first if asked to
+	err = uctx.goCMS.beforeSwap()
 	if err != nil {
-		fmt.Printf("Error applying themes file: %v\n", err.Error())
-		uctx.rollback()
-		return nil
+		return uctx.rollback(err)
+	}
+
+	// migrate data for the new version
+	uctx.migrations, err = loadMigrations(uctx.installDir, uctx.stagingDir, uctx.verbose)
+	if err == nil {
+		err = uctx.migrations.run()
+	}
+	if err != nil {
+		return uctx.rollback(err)
 	}
 
 	// move everything into production
@@ -105,8 +172,13 @@ func cmd_update(c *cli.Context) error {
 	err = utility.Copy(uctx.stagingDir, uctx.installDir, false, uctx.verbose)
 	if err != nil {
 		fmt.Printf("Erorr moving staging into production: %v\n", err.Error())
-		uctx.rollback()
-		return nil
+		return uctx.rollback(err)
+	}
+
+	// run the new version and go back to the old one if it doesn't come up
+	err = uctx.goCMS.afterSwap()
+	if err != nil {
+		return uctx.rollback(err)
 	}
 
 	// clean up
@@ -120,16 +192,33 @@ func cmd_update(c *cli.Context) error {
 		fmt.Printf("Error removing staging: %v\n", err.Error())
 	}
 
-	fmt.Println("GoCMS Installed Updated!\n")
+	fmt.Println("GoCMS Installed Updated!")
+	utility.SetResult("directory", uctx.installDir)
+	utility.SetResult("version", uctx.source.InstalledVersion(uctx.installDir))
+	utility.SetResult("source", uctx.source.String())
 
 	return nil
 }
 
-func (uctx *updatePluginContext) rollback() {
+// rollback restores the backup after the update failed with updateErr. Migrations are undone first while the
+// release whose restore step does that is still staged. The update error is returned as a copy failure unless
+// the rollback itself fails.
+func (uctx *updatePluginContext) rollback(updateErr error) error {
 	fmt.Print("Rolling back changes...\n")
-	err := utility.Copy(uctx.backupDir, uctx.installDir, false, uctx.verbose)
+	if uctx.migrations != nil {
+		err := uctx.migrations.undo()
+		if err != nil {
+			errStr := fmt.Sprintf("%v. The backup is kept in %v.", err.Error(), uctx.backupDir)
+			fmt.Println(errStr)
+			return utility.NewError(utility.KindRollback, errors.New(errStr))
+		}
+	}
+
+	err := uctx.restoreBackup()
 	if err != nil {
-		fmt.Printf("Error moving backup into production: %v\n", err.Error())
+		errStr := fmt.Sprintf("Error moving backup into production: %v. The backup is kept in %v.", err.Error(), uctx.backupDir)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindRollback, errors.New(errStr))
 	}
 	err = os.RemoveAll(uctx.backupDir)
 	if err != nil {
@@ -139,5 +228,149 @@ func (uctx *updatePluginContext) rollback() {
 	if err != nil {
 		fmt.Printf("Error removing staging: %v\n", err.Error())
 	}
+
+	err = uctx.goCMS.afterRollback()
+	if err != nil {
+		return utility.NewError(utility.KindRollback, err)
+	}
+	fmt.Print("Complete!\n")
+
+	return utility.NewError(utility.KindCopy, updateErr)
+}
+
+// keepFromBackup copies p from the backup over the same path in staging.
+func (uctx *updatePluginContext) keepFromBackup(p string) error {
+	src := filepath.Join(uctx.backupDir, p)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	dest := filepath.Join(uctx.stagingDir, p)
+	err := os.RemoveAll(dest)
+	if err != nil {
+		return err
+	}
+	return utility.Copy(src, dest, false, uctx.verbose)
+}
+
+// restoreBackup puts the installation back the way it was backed up. Everything else is removed first so no
+// file of the new version is left behind among the old ones. Logs, migration backups and the pid file are left
+// alone since they are newer than the backup.
+func (uctx *updatePluginContext) restoreBackup() error {
+	keep := map[string]bool{config.BACKUP_DIR: true, config.STAGING_DIR: true, config.BACKUPS_DIR: true, config.LOGS_DIR: true, config.PID_FILE: true}
+	entries, err := ioutil.ReadDir(uctx.installDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if keep[entry.Name()] {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(uctx.installDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	var ignore []string
+	for name := range keep {
+		ignore = append(ignore, "^"+regexp.QuoteMeta(filepath.Join(uctx.backupDir, name))+"($|"+regexp.QuoteMeta(string(filepath.Separator))+")")
+	}
+	return utility.Copy(uctx.backupDir, uctx.installDir, false, uctx.verbose, ignore...)
+}
+
+// releaseUpdate installs the update as a new release and switches to it. The live release is never written to,
+// so a failed update leaves it as it was and the previous release stays around to roll back to.
+func releaseUpdate(siteDir string, source install.InstallSource, goCMS *runningGoCMS, keep int, verbose bool) error {
+	previous, err := utility.CurrentRelease(siteDir)
+	if err != nil {
+		fmt.Printf("Error reading current release: %v\n", err.Error())
+		return utility.NewError(utility.KindInvalidInstall, err)
+	}
+
+	release, err := install.PrepareRelease(siteDir, source, verbose)
+	if err != nil {
+		return err
+	}
+
+	var migrations *releaseMigrations
+	err = goCMS.beforeSwap()
+	if err == nil {
+		migrations, err = loadMigrations(siteDir, utility.ReleaseDir(siteDir, release), verbose)
+	}
+	if err == nil {
+		err = migrations.run()
+	}
+	if err == nil {
+		err = install.SwitchToRelease(siteDir, release)
+	}
+	if err == nil {
+		err = goCMS.afterSwap()
+	}
+	if err != nil {
+		return rollbackRelease(siteDir, previous, release, goCMS, migrations, err)
+	}
+
+	removed, err := utility.PruneReleases(siteDir, keep)
+	if err != nil {
+		fmt.Printf("Error removing old releases: %v\n", err.Error())
+	}
+	for _, name := range removed {
+		fmt.Printf("Removed old release %v\n", name)
+	}
+
+	fmt.Printf("GoCMS Updated! Run 'gcm releases rollback %v' to switch back to %v.\n", siteDir, previous)
+	utility.SetResult("directory", siteDir)
+	utility.SetResult("version", source.InstalledVersion(siteDir))
+	utility.SetResult("source", source.String())
+	utility.SetResult("release", release)
+	utility.SetResult("previous", previous)
+
+	return nil
+}
+
+// rollbackRelease switches back to the previous release after the update to release failed with updateErr.
+// The failed release is removed so it can't be rolled back to.
+func rollbackRelease(siteDir string, previous string, release string, goCMS *runningGoCMS, migrations *releaseMigrations, updateErr error) error {
+	fmt.Print("Rolling back changes...\n")
+	current, _ := utility.CurrentRelease(siteDir)
+	if current != previous {
+		err := utility.SwitchRelease(siteDir, previous)
+		if err != nil {
+			errStr := fmt.Sprintf("Error switching back to release %v: %v", previous, err.Error())
+			fmt.Println(errStr)
+			return utility.NewError(utility.KindRollback, errors.New(errStr))
+		}
+	}
+
+	if migrations != nil {
+		err := migrations.undo()
+		if err != nil {
+			return utility.NewError(utility.KindRollback, err)
+		}
+	}
+
+	err := goCMS.afterRollback()
+	if err != nil {
+		return utility.NewError(utility.KindRollback, err)
+	}
+
+	err = os.RemoveAll(utility.ReleaseDir(siteDir, release))
+	if err != nil {
+		fmt.Printf("Error removing release %v: %v\n", release, err.Error())
+	}
 	fmt.Print("Complete!\n")
+
+	return utility.NewError(utility.KindCopy, updateErr)
+}
+
+// updateDetail sums up the update of one site for the table printed by update --all.
+func updateDetail(result map[string]interface{}) string {
+	detail := fmt.Sprintf("%v", result["source"])
+	if release, ok := result["release"]; ok {
+		detail = fmt.Sprintf("release %v", release)
+	}
+	if version, ok := result["version"]; ok && version != "" {
+		detail += fmt.Sprintf(", version %v", version)
+	}
+	return detail
 }
@@ -0,0 +1,127 @@
+package versions
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/commands/sites"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+)
+
+const flag_verbose = "verbose"
+
+var CMD_VERSION = cli.Command{
+	Name:      "version",
+	Usage:     "Show the version of gcm. With --verbose also how it was built and, inside an installation, what is installed.",
+	ArgsUsage: "[directory]",
+	Action:    cmd_version,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  flag_verbose,
+			Usage: "Also report the go version, os, release urls and the installation in the directory. Defaults to the current directory.",
+		},
+	},
+}
+
+type pluginInfo struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func cmd_version(c *cli.Context) error {
+	build := utility.CurrentGcmBuild()
+	fmt.Printf("gcm %v\n", build.String())
+	utility.SetResult("version", build.Version)
+	utility.SetResult("commit", build.Commit)
+	utility.SetResult("buildDate", build.BuildDate)
+	utility.SetResult("commitDate", build.CommitDate)
+
+	if !c.Bool(flag_verbose) && !c.GlobalBool(config.FLAG_VERBOSE) {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "commit:\t%v\n", valueOrUnknown(build.Commit))
+	if build.CommitDate != "" {
+		fmt.Fprintf(w, "committed:\t%v\n", build.CommitDate)
+	}
+	fmt.Fprintf(w, "built:\t%v\n", valueOrUnknown(build.BuildDate))
+	fmt.Fprintf(w, "go:\t%v\n", build.GoVersion)
+	fmt.Fprintf(w, "os/arch:\t%v/%v (%v)\n", runtime.GOOS, runtime.GOARCH, config.BINARY_OS_PATH)
+	fmt.Fprintf(w, "gocms release:\t%v\n", utility.ReleaseUrl(config.BINARY_DEFAULT_VERSION))
+	fmt.Fprintf(w, "gcm index:\t%v\n", utility.GcmIndexUrl())
+	w.Flush()
+
+	utility.SetResult("go", build.GoVersion)
+	utility.SetResult("os", runtime.GOOS)
+	utility.SetResult("arch", runtime.GOARCH)
+	utility.SetResult("osPath", config.BINARY_OS_PATH)
+	utility.SetResult("releaseUrl", utility.ReleaseUrl(config.BINARY_DEFAULT_VERSION))
+	utility.SetResult("gcmIndexUrl", utility.GcmIndexUrl())
+
+	args, err := sites.Args(c, 0)
+	if err != nil {
+		return err
+	}
+	siteDir := "."
+	if args.Present() {
+		siteDir = args.First()
+	}
+	return reportInstallation(filepath.Clean(siteDir))
+}
+
+// reportInstallation prints the gocms version and plugins installed in siteDir. Directories that aren't an
+// installation are skipped.
+func reportInstallation(siteDir string) error {
+	installDir := utility.ActiveInstallDir(siteDir)
+	if _, err := os.Stat(filepath.Join(installDir, config.BINARY_FILE)); err != nil {
+		return nil
+	}
+
+	fmt.Printf("\nGoCMS installation %v\n", siteDir)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	gocmsVersion := utility.InstalledGoCMSVersion(siteDir)
+	fmt.Fprintf(w, "gocms:\t%v\n", valueOrUnknown(gocmsVersion))
+	if release, err := utility.CurrentRelease(siteDir); err == nil {
+		fmt.Fprintf(w, "release:\t%v\n", release)
+		utility.SetResult("release", release)
+	}
+	w.Flush()
+	utility.SetResult("directory", siteDir)
+	utility.SetResult("gocmsVersion", gocmsVersion)
+
+	manifests, err := utility.InstalledPlugins(siteDir)
+	if err != nil {
+		fmt.Printf("Error reading installed plugins: %v\n", err.Error())
+		return utility.NewError(utility.KindInvalidInstall, err)
+	}
+
+	plugins := []pluginInfo{}
+	if len(manifests) == 0 {
+		fmt.Println("No plugins installed.")
+	} else {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PLUGIN\tNAME\tVERSION")
+		for _, manifest := range manifests {
+			plugins = append(plugins, pluginInfo{Id: manifest.Id, Name: manifest.Name, Version: manifest.Version})
+			fmt.Fprintf(w, "%v\t%v\t%v\n", manifest.Id, manifest.Name, valueOrUnknown(manifest.Version))
+		}
+		w.Flush()
+	}
+	utility.SetResult("plugins", plugins)
+
+	return nil
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
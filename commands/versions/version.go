@@ -2,11 +2,13 @@ package versions
 
 import (
 	"fmt"
+	"github.com/gocms-io/gcm/utility"
+	"github.com/gocms-io/gocms/utility/errors"
 	"github.com/urfave/cli"
-	"io"
-	"log"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 )
 
 var CMD_VERSIONS = cli.Command{
@@ -17,17 +19,41 @@ var CMD_VERSIONS = cli.Command{
 
 func cmd_versions(c *cli.Context) error {
 
-	response, err := http.Get("http://release.gocms.io/alpha-release/versions.txt")
+	req, err := http.NewRequest(http.MethodGet, "http://release.gocms.io/alpha-release/versions.txt", nil)
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		defer response.Body.Close()
-		_, err := io.Copy(os.Stdout, response.Body)
-		if err != nil {
-			log.Fatal(err)
+		return err
+	}
+	response, err := utility.DownloadRequest(req)
+	if err != nil {
+		fmt.Printf("Error getting versions: %v\n", err.Error())
+		return utility.NewError(utility.KindDownload, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		errStr := fmt.Sprintf("Error getting versions: %v", response.Status)
+		fmt.Println(errStr)
+		return utility.NewError(utility.KindDownload, errors.New(errStr))
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		fmt.Printf("Error reading versions: %v\n", err.Error())
+		return utility.NewError(utility.KindDownload, err)
+	}
+
+	// the list is only needed as a result in json mode
+	if utility.JSONOutput() {
+		versions := []string{}
+		for _, version := range strings.Split(string(body), "\n") {
+			if version = strings.TrimSpace(version); version != "" {
+				versions = append(versions, version)
+			}
 		}
+		utility.SetResult("versions", versions)
+		return nil
 	}
 
+	os.Stdout.Write(body)
 	fmt.Println("")
 
 	return nil
@@ -5,6 +5,16 @@ import "github.com/gocms-io/gcm/config/config_os"
 // global flags
 const FLAG_VERBOSE = "verbose"
 const FLAG_SET_VERSION = "useVersion"
+const FLAG_OUTPUT = "output"
+const FLAG_QUIET = "quiet"
+const FLAG_OFFLINE = "offline"
+const FLAG_DOWNLOAD_RETRIES = "downloadRetries"
+const FLAG_DOWNLOAD_TIMEOUT = "downloadTimeout"
+const FLAG_CA_CERT = "caCert"
+const FLAG_DOWNLOAD_USER = "downloadUser"
+const FLAG_DOWNLOAD_PASSWORD = "downloadPassword"
+const FLAG_DOWNLOAD_TOKEN = "downloadToken"
+const FLAG_SITE = "site"
 
 // binary items
 const BINARY_PROTOCOL = "http"
@@ -16,6 +26,17 @@ const BINARY_FILE = config_os.BINARY_FILE
 const BINARY_DEFAULT_RELEASE = "alpha-release"
 const BINARY_DEFAULT_VERSION = "current"
 
+// gcm's own builds. gcm/index.json on the release host names the newest version and where each build is.
+const GCM_RELEASE = "gcm"
+const GCM_INDEX = "index.json"
+const GCM_BINARY_FILE = config_os.GCM_BINARY_FILE
+
+// set by build.sh with -ldflags "-X github.com/gocms-io/gcm/config.GCM_VERSION=<version>" and likewise for the
+// commit and build date. builds without them fall back to what go records about the build.
+var GCM_VERSION = "0.0.1"
+var GCM_COMMIT = ""
+var GCM_BUILD_DATE = ""
+
 // other dirs and files
 const CONTENT_DIR = "content"
 const ENV_FILE = ".env"
@@ -28,3 +49,31 @@ const GOCMS_ADMIN_DIR = "gocms"
 const BACKUP_DIR = ".bk"
 const STAGING_DIR = ".staging"
 const PLUGIN_MANIFEST = "manifest.json"
+const RELEASE_MANIFEST = "release.json"
+const INSTALL_INFO_FILE = "gcm-install.json"
+const BACKUPS_DIR = "backups"
+const PLUGIN_CONFIG = "gcm.yaml"
+const ROUTE_TESTS_DIR = "routetests"
+
+// release layout. each release lives in releases/<name> and current links to the live one.
+const RELEASES_DIR = "releases"
+const CURRENT_RELEASE_LINK = "current"
+const SHARED_DIR = "shared"
+
+// running gocms
+const GOCMS_PORT_ENV = "PORT"
+const GOCMS_DEFAULT_PORT = "8080"
+const GOCMS_RELOAD_PLUGIN_PATH = "/api/plugins/%v/reload"
+const PID_FILE = "gocms.pid"
+const LOGS_DIR = "logs"
+const LOG_FILE = "gocms.log"
+
+// cross compile targets (GOOS/GOARCH) mapped to their os path. matches the matrix in build.sh.
+var BUILD_TARGETS = map[string]string{
+	"linux/amd64":   "linux_64",
+	"linux/386":     "linux_32",
+	"linux/arm":     "linux_arm",
+	"darwin/amd64":  "osx_64",
+	"windows/amd64": "windows_64",
+	"windows/386":   "windows_32",
+}
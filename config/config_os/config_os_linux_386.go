@@ -2,4 +2,5 @@ package config_os
 
 const BINARY_OS_PATH = "linux_32"
 const BINARY_FILE = "gocms"
+const GCM_BINARY_FILE = "gcm"
 
@@ -2,3 +2,4 @@ package config_os
 
 const BINARY_OS_PATH = "linux_64"
 const BINARY_FILE = "gocms"
+const GCM_BINARY_FILE = "gcm"
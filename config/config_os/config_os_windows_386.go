@@ -2,4 +2,5 @@ package config_os
 
 const BINARY_OS_PATH = "windows_32"
 const BINARY_FILE = "gocms.exe"
+const GCM_BINARY_FILE = "gcm.exe"
 
@@ -2,3 +2,4 @@ package config_os
 
 const BINARY_OS_PATH = "windows_64"
 const BINARY_FILE = "gocms.exe"
+const GCM_BINARY_FILE = "gcm.exe"
@@ -1,6 +1,7 @@
 #!/bin/bash
 
 VERSION=0.0.$TRAVIS_BUILD_NUMBER
+RELEASE_BRANCH=master
 
 # vars for testing. comment out for release.
 #AWS_SECRET=
@@ -17,4 +18,20 @@ AWS_ACCESS_KEY_ID=$AWS_KEY AWS_SECRET_ACCESS_KEY=$AWS_SECRET aws s3 cp \
 
 # copy files to build number bucket
 AWS_ACCESS_KEY_ID=$AWS_KEY AWS_SECRET_ACCESS_KEY=$AWS_SECRET aws s3 cp \
-    --recursive s3://release.gocms.io/$TRAVIS_BRANCH/current/ s3://release.gocms.io/$TRAVIS_BRANCH/$VERSION/
\ No newline at end of file
+    --recursive s3://release.gocms.io/$TRAVIS_BRANCH/current/ s3://release.gocms.io/$TRAVIS_BRANCH/$VERSION/
+
+# publish the gcm index used by gcm self-update. every install updates from it, so only the release branch may.
+if [ "$TRAVIS_BRANCH" != "$RELEASE_BRANCH" ]; then
+    echo not publishing the gcm index from $TRAVIS_BRANCH
+    exit 0
+fi
+echo write gcm index
+BUILDS=""
+for OS_PATH in bin/$TRAVIS_BRANCH/*/; do
+    OS_PATH=$(basename $OS_PATH)
+    SUM=$(sha256sum bin/$TRAVIS_BRANCH/$OS_PATH/gocms.zip | cut -d' ' -f1)
+    BUILDS="$BUILDS${BUILDS:+,}\"$OS_PATH\": {\"url\": \"http://release.gocms.io/$TRAVIS_BRANCH/$VERSION/$OS_PATH/gocms.zip\", \"sha256\": \"$SUM\"}"
+done
+echo "{\"version\": \"$VERSION\", \"builds\": {$BUILDS}}" > bin/index.json
+AWS_ACCESS_KEY_ID=$AWS_KEY AWS_SECRET_ACCESS_KEY=$AWS_SECRET aws s3 cp \
+    bin/index.json s3://release.gocms.io/gcm/index.json
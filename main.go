@@ -1,14 +1,25 @@
 package main
 
 import (
+	"fmt"
+	"github.com/gocms-io/gcm/commands/cache"
+	"github.com/gocms-io/gcm/commands/check"
 	"github.com/gocms-io/gcm/commands/developer"
 	"github.com/gocms-io/gcm/commands/install"
+	"github.com/gocms-io/gcm/commands/plugin"
+	"github.com/gocms-io/gcm/commands/releases"
+	"github.com/gocms-io/gcm/commands/run"
+	"github.com/gocms-io/gcm/commands/selfupdate"
+	"github.com/gocms-io/gcm/commands/sites"
 	"github.com/gocms-io/gcm/commands/update"
 	"github.com/gocms-io/gcm/commands/versions"
 	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility"
 	cli "github.com/urfave/cli"
 	"os"
 	"sort"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -17,11 +28,23 @@ func main() {
 	app.Name = "GoCMS Manager (gcm)"
 	app.Usage = "Interface to manage all things GoCMS"
 	app.HelpName = "gcm"
-	app.Version = "0.0.1"
+	app.Version = utility.CurrentGcmBuild().String()
 	app.Commands = []cli.Command{
+		cache.CMD_CACHE,
+		check.CMD_CHECK,
 		developer.CMD_DEVELOPER,
 		install.CMD_INSTALL,
+		plugin.CMD_PLUGIN,
+		releases.CMD_RELEASES,
+		run.CMD_RUN,
+		run.CMD_STOP,
+		run.CMD_RESTART,
+		run.CMD_LOGS,
+		run.CMD_STATUS,
+		selfupdate.CMD_SELF_UPDATE,
+		sites.CMD_SITES,
 		update.CMD_UPDATE,
+		versions.CMD_VERSION,
 		versions.CMD_VERSIONS,
 	}
 
@@ -34,13 +57,103 @@ func main() {
 			Name:  config.FLAG_SET_VERSION,
 			Usage: "Set the version to use for updates or install. Defaults to current.",
 		},
+		cli.StringFlag{
+			Name:  config.FLAG_OUTPUT,
+			Value: utility.OutputText,
+			Usage: "Output format: text or json. json writes one event per line.",
+		},
+		cli.BoolFlag{
+			Name:  config.FLAG_QUIET,
+			Usage: "Don't report progress of downloads, unpacking and copying.",
+		},
+		cli.BoolFlag{
+			Name:  config.FLAG_OFFLINE,
+			Usage: "Only use downloads that are already cached. Fails straight away when one isn't.",
+		},
+		cli.IntFlag{
+			Name:  config.FLAG_DOWNLOAD_RETRIES,
+			Value: 3,
+			Usage: "How many times to retry a failed download.",
+		},
+		cli.DurationFlag{
+			Name:  config.FLAG_DOWNLOAD_TIMEOUT,
+			Value: 30 * time.Second,
+			Usage: "How long to wait to connect to a download server and how long a download may stall.",
+		},
+		cli.StringFlag{
+			Name:   config.FLAG_CA_CERT,
+			EnvVar: "GCM_CA_CERT",
+			Usage:  "PEM bundle of extra certificate authorities to trust for downloads.",
+		},
+		cli.StringFlag{
+			Name:   config.FLAG_DOWNLOAD_USER,
+			EnvVar: "GCM_DOWNLOAD_USER",
+			Usage:  "User for basic auth against a private mirror.",
+		},
+		cli.StringFlag{
+			Name:   config.FLAG_DOWNLOAD_PASSWORD,
+			EnvVar: "GCM_DOWNLOAD_PASSWORD",
+			Usage:  "Password for basic auth against a private mirror. Prefer the environment variable.",
+		},
+		cli.StringFlag{
+			Name:  config.FLAG_SITE,
+			Usage: "Run the command for the installation registered under this name instead of giving its directory. See 'gcm sites'.",
+		},
+		cli.StringFlag{
+			Name:   config.FLAG_DOWNLOAD_TOKEN,
+			EnvVar: "GCM_DOWNLOAD_TOKEN",
+			Usage:  "Bearer token for a private mirror. Prefer the environment variable.",
+		},
+	}
+
+	app.Before = func(c *cli.Context) error {
+		utility.SetQuiet(c.Bool(config.FLAG_QUIET))
+		utility.SetOffline(c.Bool(config.FLAG_OFFLINE))
+		utility.SetDownloadOptions(utility.DownloadOptions{
+			Retries:  c.Int(config.FLAG_DOWNLOAD_RETRIES),
+			Timeout:  c.Duration(config.FLAG_DOWNLOAD_TIMEOUT),
+			CACert:   c.String(config.FLAG_CA_CERT),
+			Username: c.String(config.FLAG_DOWNLOAD_USER),
+			Password: c.String(config.FLAG_DOWNLOAD_PASSWORD),
+			Token:    c.String(config.FLAG_DOWNLOAD_TOKEN),
+		})
+
+		err := utility.SetOutputFormat(c.String(config.FLAG_OUTPUT), commandPath(app, c.Args()))
+		if err != nil {
+			fmt.Println(err.Error())
+			return utility.NewError(utility.KindUsage, err)
+		}
+		return nil
 	}
 
 	sort.Sort(cli.FlagsByName(app.Flags))
 	sort.Sort(cli.CommandsByName(app.Commands))
 
 	err := app.Run(os.Args)
+	kind := utility.ErrorKindOf(err)
+	utility.FinishOutput(err, kind.Code())
 	if err != nil {
-		os.Exit(1)
+		os.Exit(kind.ExitStatus())
+	}
+}
+
+// commandPath names the command being run including any subcommand. ex: developer plugin
+func commandPath(app *cli.App, args cli.Args) string {
+	var path []string
+	commands := app.Commands
+	for _, arg := range args {
+		var found *cli.Command
+		for i := range commands {
+			if commands[i].HasName(arg) {
+				found = &commands[i]
+				break
+			}
+		}
+		if found == nil {
+			break
+		}
+		path = append(path, found.Name)
+		commands = found.Subcommands
 	}
+	return strings.Join(path, " ")
 }
@@ -0,0 +1,14 @@
+package models
+
+// GcmIndex names the newest gcm and where to get it. It is read from gcm/index.json on the release host.
+type GcmIndex struct {
+	Version string `json:"version"`
+	// Builds are keyed by os path, ex: linux_64
+	Builds map[string]*GcmBuild `json:"builds"`
+}
+
+// GcmBuild is the archive holding gcm for one os and the sha256 it must match.
+type GcmBuild struct {
+	Url    string `json:"url"`
+	Sha256 string `json:"sha256"`
+}
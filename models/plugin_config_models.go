@@ -0,0 +1,39 @@
+package models
+
+// PluginConfig holds development settings for a plugin that gocms itself never reads.
+// It is loaded from gcm.yaml next to the plugin manifest.
+type PluginConfig struct {
+	Hooks PluginHooks `json:"hooks" yaml:"hooks"`
+	Build PluginBuild `json:"build" yaml:"build"`
+}
+
+// PluginBuild controls how the plugin binary is compiled. VersionVar and BuildVar name the package
+// variables (ex: main.version) that receive the manifest version and build number through -ldflags -X.
+type PluginBuild struct {
+	Tags       []string          `json:"tags" yaml:"tags"`
+	LdFlags    string            `json:"ldflags" yaml:"ldflags"`
+	VersionVar string            `json:"versionVar" yaml:"versionVar"`
+	BuildVar   string            `json:"buildVar" yaml:"buildVar"`
+	Race       bool              `json:"race" yaml:"race"`
+	TrimPath   bool              `json:"trimpath" yaml:"trimpath"`
+	Cgo        *bool             `json:"cgo" yaml:"cgo"`
+	Env        map[string]string `json:"env" yaml:"env"`
+	Targets    []string          `json:"targets" yaml:"targets"`
+}
+
+type PluginHooks struct {
+	PreBuild  []*PluginHook `json:"preBuild" yaml:"preBuild"`
+	PostBuild []*PluginHook `json:"postBuild" yaml:"postBuild"`
+	PreCopy   []*PluginHook `json:"preCopy" yaml:"preCopy"`
+	PostCopy  []*PluginHook `json:"postCopy" yaml:"postCopy"`
+	PreRun    []*PluginHook `json:"preRun" yaml:"preRun"`
+}
+
+type PluginHook struct {
+	Name            string            `json:"name" yaml:"name"`
+	Command         string            `json:"command" yaml:"command"`
+	Dir             string            `json:"dir" yaml:"dir"`
+	Env             map[string]string `json:"env" yaml:"env"`
+	Timeout         string            `json:"timeout" yaml:"timeout"`
+	ContinueOnError bool              `json:"continueOnError" yaml:"continueOnError"`
+}
@@ -11,6 +11,7 @@ type PluginManifest struct {
 	AuthorEmail string          `json:"authorEmail"`
 	Services    PluginServices  `json:"services"`
 	Interface   PluginInterface `json:"interface"`
+	Hooks       PluginHooks     `json:"hooks"`
 }
 
 type PluginManifestRoute struct {
@@ -0,0 +1,24 @@
+package models
+
+// ReleaseManifest describes a gocms release. It is read from release.json at the root of the release.
+type ReleaseManifest struct {
+	Version string `json:"version"`
+	// MinGcmVersion is the oldest gcm that knows how to install the release
+	MinGcmVersion string `json:"minGcmVersion"`
+	// Backup runs before the migrations, ex: to dump the database into GCM_BACKUP_DIR
+	Backup *ReleaseStep `json:"backup"`
+	// Migrations bring data up to date for the release. They run in order after the release is staged
+	// and before it goes into production.
+	Migrations []*ReleaseStep `json:"migrations"`
+	// Restore undoes what the migrations did when the update is rolled back, ex: from the backup
+	Restore *ReleaseStep `json:"restore"`
+}
+
+// ReleaseStep runs either the release's own gocms binary with the GoCMS arguments or a Script from the release.
+type ReleaseStep struct {
+	Name    string            `json:"name"`
+	GoCMS   string            `json:"gocms"`
+	Script  string            `json:"script"`
+	Env     map[string]string `json:"env"`
+	Timeout string            `json:"timeout"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "encoding/json"
+
+// RouteFixture describes how to call a plugin route and what to expect back. Fixtures are stored
+// beside the plugin manifest in routetests/<route name>.json.
+type RouteFixture struct {
+	Params  map[string]string `json:"params"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+	Expect  RouteExpectation  `json:"expect"`
+}
+
+type RouteExpectation struct {
+	Status   int             `json:"status"`
+	Body     json.RawMessage `json:"body"`
+	Contains string          `json:"contains"`
+}
@@ -0,0 +1,181 @@
+package utility
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ArchiveFormat int
+
+const (
+	ArchiveUnknown ArchiveFormat = iota
+	ArchiveZip
+	ArchiveTar
+	ArchiveTarGz
+	ArchiveTarZst
+)
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case ArchiveZip:
+		return "zip"
+	case ArchiveTar:
+		return "tar"
+	case ArchiveTarGz:
+		return "tar.gz"
+	case ArchiveTarZst:
+		return "tar.zst"
+	}
+	return "unknown"
+}
+
+// archiveExtensions maps file extensions to formats. Longer extensions come first so .tar.gz isn't taken for .gz.
+var archiveExtensions = []struct {
+	ext    string
+	format ArchiveFormat
+}{
+	{".tar.gz", ArchiveTarGz},
+	{".tgz", ArchiveTarGz},
+	{".tar.zst", ArchiveTarZst},
+	{".tzst", ArchiveTarZst},
+	{".tar", ArchiveTar},
+	{".zip", ArchiveZip},
+}
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	zipEmptyMagic = []byte("PK\x05\x06")
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zstdMagic     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	tarMagic      = []byte("ustar")
+)
+
+const tarMagicOffset = 257
+
+// ArchiveFormatFromName picks a format from the file extension of name.
+func ArchiveFormatFromName(name string) ArchiveFormat {
+	name = strings.ToLower(name)
+	for _, e := range archiveExtensions {
+		if strings.HasSuffix(name, e.ext) {
+			return e.format
+		}
+	}
+	return ArchiveUnknown
+}
+
+// DetectArchiveFormat looks at the start of the file to tell what kind of archive it is. The extension is only
+// used when the content doesn't give it away, since downloads don't always keep a meaningful name.
+func DetectArchiveFormat(path string) (ArchiveFormat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ArchiveUnknown, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return ArchiveUnknown, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic), bytes.HasPrefix(head, zipEmptyMagic):
+		return ArchiveZip, nil
+	case bytes.HasPrefix(head, gzipMagic):
+		return ArchiveTarGz, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		return ArchiveTarZst, nil
+	case len(head) >= tarMagicOffset+len(tarMagic) && bytes.Equal(head[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return ArchiveTar, nil
+	}
+
+	if format := ArchiveFormatFromName(path); format != ArchiveUnknown {
+		return format, nil
+	}
+	return ArchiveUnknown, fmt.Errorf("%v isn't a zip, tar, tar.gz or tar.zst archive", path)
+}
+
+// ExtractArchive extracts any supported archive into dest with the default limits.
+func ExtractArchive(src, dest string) error {
+	return ExtractArchiveWithLimits(src, dest, DefaultExtractLimits)
+}
+
+// ExtractArchiveWithLimits extracts src into dest under the same rules whatever the format. See UnzipWithLimits.
+func ExtractArchiveWithLimits(src, dest string, limits ExtractLimits) error {
+	format, err := DetectArchiveFormat(src)
+	if err != nil {
+		return NewError(KindUnpack, err)
+	}
+
+	if format == ArchiveZip {
+		return UnzipWithLimits(src, dest, limits)
+	}
+	return untarWithLimits(src, dest, format, limits)
+}
+
+// CreateArchive packs the contents of srcDir into dest. The format comes from dest's extension. Permissions,
+// modification times and symlinks are kept.
+func CreateArchive(dest, srcDir string) error {
+	format := ArchiveFormatFromName(dest)
+	if format == ArchiveUnknown {
+		return Errorf(KindUsage, "can't tell the archive format of %v. Use .zip, .tar, .tar.gz or .tar.zst", dest)
+	}
+
+	// write next to dest and move into place so a failure doesn't leave half an archive
+	tmpFile := dest + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+
+	if format == ArchiveZip {
+		err = writeZip(f, srcDir)
+	} else {
+		err = writeTar(f, srcDir, format)
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, dest)
+}
+
+// archiveWalk calls fn for everything under srcDir with its slash separated name inside the archive. Symlinks are
+// passed along rather than followed.
+func archiveWalk(srcDir string, fn func(name string, path string, info os.FileInfo) error) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		return fn(filepath.ToSlash(rel), path, info)
+	})
+}
+
+// progressReader counts the bytes read through it as progress.
+type progressReader struct {
+	r        io.Reader
+	progress ProgressReporter
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.progress.Add(int64(n))
+	return n, err
+}
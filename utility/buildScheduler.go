@@ -0,0 +1,125 @@
+package utility
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BuildScheduler coalesces change notifications into builds. Changes are collected until no new change
+// has arrived for QuietPeriod, only one build runs at a time, and a change during a build cancels the
+// running build and queues exactly one follow-up build with everything that changed in the meantime.
+type BuildScheduler struct {
+	QuietPeriod time.Duration
+	Build       func(ctx context.Context, changes []string)
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	pending []string
+	seen    map[string]bool
+	running bool
+	queued  bool
+	stopped bool
+	cancel  context.CancelFunc
+	builds  sync.WaitGroup
+}
+
+func NewBuildScheduler(quietPeriod time.Duration, build func(ctx context.Context, changes []string)) *BuildScheduler {
+	return &BuildScheduler{
+		QuietPeriod: quietPeriod,
+		Build:       build,
+		seen:        make(map[string]bool),
+	}
+}
+
+// Notify records a changed path and restarts the quiet period. Safe to call from any goroutine.
+func (s *BuildScheduler) Notify(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+	if !s.seen[path] {
+		s.seen[path] = true
+		s.pending = append(s.pending, path)
+	}
+
+	// newer changes make the running build stale
+	if s.running && s.cancel != nil {
+		s.cancel()
+	}
+
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.QuietPeriod, s.fire)
+	} else {
+		s.timer.Reset(s.QuietPeriod)
+	}
+}
+
+// Stop cancels any pending or running build and waits for a running build to return. Changes notified
+// after Stop are ignored.
+func (s *BuildScheduler) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.pending = nil
+	s.seen = make(map[string]bool)
+	s.queued = false
+	s.mu.Unlock()
+
+	s.builds.Wait()
+}
+
+func (s *BuildScheduler) fire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	// wait for the running build to finish
+	if s.running {
+		s.queued = true
+		return
+	}
+
+	s.start()
+}
+
+// start must be called with the lock held.
+func (s *BuildScheduler) start() {
+	if len(s.pending) == 0 {
+		return
+	}
+
+	changes := s.pending
+	s.pending = nil
+	s.seen = make(map[string]bool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.running = true
+	s.cancel = cancel
+	s.builds.Add(1)
+
+	go func() {
+		defer s.builds.Done()
+		s.Build(ctx, changes)
+		cancel()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.running = false
+		s.cancel = nil
+		if s.queued {
+			s.queued = false
+			s.start()
+		}
+	}()
+}
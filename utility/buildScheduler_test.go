@@ -0,0 +1,179 @@
+package utility
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testQuietPeriod = 20 * time.Millisecond
+
+// testBuild records the builds a BuildScheduler starts. Builds block until release is closed or, unless
+// ignoreCancel is set, they are cancelled.
+type testBuild struct {
+	ignoreCancel bool
+
+	mu        sync.Mutex
+	builds    [][]string
+	cancelled []bool
+	running   int
+	overlap   bool
+	started   chan []string
+	release   chan struct{}
+}
+
+func newTestBuild() *testBuild {
+	return &testBuild{started: make(chan []string, 10), release: make(chan struct{})}
+}
+
+func (b *testBuild) build(ctx context.Context, changes []string) {
+	b.mu.Lock()
+	b.running++
+	if b.running > 1 {
+		b.overlap = true
+	}
+	b.builds = append(b.builds, changes)
+	b.mu.Unlock()
+	b.started <- changes
+
+	done := ctx.Done()
+	if b.ignoreCancel {
+		done = nil
+	}
+	select {
+	case <-done:
+	case <-b.release:
+	}
+	cancelled := ctx.Err() != nil
+
+	b.mu.Lock()
+	b.running--
+	b.cancelled = append(b.cancelled, cancelled)
+	b.mu.Unlock()
+}
+
+func (b *testBuild) waitStarted(t *testing.T) []string {
+	select {
+	case changes := <-b.started:
+		return changes
+	case <-time.After(2 * time.Second):
+		t.Fatal("no build started")
+		return nil
+	}
+}
+
+func (b *testBuild) expectNoBuild(t *testing.T, wait time.Duration) {
+	select {
+	case changes := <-b.started:
+		t.Fatalf("unexpected build of %v", changes)
+	case <-time.After(wait):
+	}
+}
+
+func TestBuildSchedulerCoalesces(t *testing.T) {
+	b := newTestBuild()
+	close(b.release)
+	s := NewBuildScheduler(testQuietPeriod, b.build)
+	defer s.Stop()
+
+	s.Notify("a")
+	s.Notify("b")
+	s.Notify("a")
+	if changes := b.waitStarted(t); !reflect.DeepEqual(changes, []string{"a", "b"}) {
+		t.Errorf("built %v, expected [a b]", changes)
+	}
+	b.expectNoBuild(t, 5*testQuietPeriod)
+}
+
+func TestBuildSchedulerWaitsForQuiet(t *testing.T) {
+	b := newTestBuild()
+	close(b.release)
+	s := NewBuildScheduler(4*testQuietPeriod, b.build)
+	defer s.Stop()
+
+	// each change restarts the quiet period
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		s.Notify("a")
+		time.Sleep(testQuietPeriod)
+	}
+	b.waitStarted(t)
+	if waited := time.Since(start); waited < 8*testQuietPeriod {
+		t.Errorf("the build started after %v, before the changes were quiet", waited)
+	}
+}
+
+func TestBuildSchedulerCancelsStaleBuild(t *testing.T) {
+	b := newTestBuild()
+	b.ignoreCancel = true
+	s := NewBuildScheduler(testQuietPeriod, b.build)
+	defer s.Stop()
+
+	s.Notify("a")
+	b.waitStarted(t)
+
+	// changes during a build cancel it and queue one follow-up with all of them once it returns
+	s.Notify("b")
+	s.Notify("c")
+	time.Sleep(3 * testQuietPeriod)
+	s.Notify("d")
+	b.expectNoBuild(t, 3*testQuietPeriod)
+	close(b.release)
+
+	if changes := b.waitStarted(t); !reflect.DeepEqual(changes, []string{"b", "c", "d"}) {
+		t.Errorf("follow-up built %v, expected [b c d]", changes)
+	}
+	b.expectNoBuild(t, 5*testQuietPeriod)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.overlap {
+		t.Error("builds overlapped")
+	}
+	if len(b.cancelled) != 2 || !b.cancelled[0] || b.cancelled[1] {
+		t.Errorf("expected only the stale build to be cancelled, got %v", b.cancelled)
+	}
+}
+
+func TestBuildSchedulerStopWaitsForBuild(t *testing.T) {
+	b := newTestBuild()
+	var mu sync.Mutex
+	finished := false
+	s := NewBuildScheduler(testQuietPeriod, func(ctx context.Context, changes []string) {
+		b.build(ctx, changes)
+		time.Sleep(2 * testQuietPeriod)
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+	})
+
+	s.Notify("a")
+	b.waitStarted(t)
+	s.Stop()
+
+	mu.Lock()
+	if !finished {
+		t.Error("Stop returned before the running build")
+	}
+	mu.Unlock()
+	b.mu.Lock()
+	if len(b.cancelled) != 1 || !b.cancelled[0] {
+		t.Error("Stop didn't cancel the running build")
+	}
+	b.mu.Unlock()
+
+	s.Notify("b")
+	b.expectNoBuild(t, 5*testQuietPeriod)
+}
+
+func TestBuildSchedulerStopDropsPending(t *testing.T) {
+	b := newTestBuild()
+	close(b.release)
+	s := NewBuildScheduler(testQuietPeriod, b.build)
+
+	s.Notify("a")
+	s.Stop()
+	b.expectNoBuild(t, 5*testQuietPeriod)
+}
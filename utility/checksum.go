@@ -0,0 +1,72 @@
+package utility
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// checksumSuffix is appended to the url of a file to find its published sha256.
+const checksumSuffix = ".sha256"
+
+// FileChecksum returns the hex encoded sha256 of a file's contents.
+func FileChecksum(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FetchChecksum returns the sha256 published next to fileUrl, in the format of sha256sum. It returns an empty
+// checksum when none is published or gcm is offline.
+func FetchChecksum(fileUrl string) (string, error) {
+	if offline {
+		return "", nil
+	}
+
+	checksumUrl := fileUrl + checksumSuffix
+	req, err := http.NewRequest(http.MethodGet, checksumUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := DownloadRequest(req)
+	if err != nil {
+		return "", NewError(KindDownload, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden:
+		// s3 answers 403 for missing files of a bucket that can't be listed
+		return "", nil
+	case resp.StatusCode != http.StatusOK:
+		return "", Errorf(KindDownload, "%v responded with %v", checksumUrl, resp.Status)
+	}
+
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", NewError(KindDownload, err)
+	}
+	fields := strings.Fields(string(raw))
+	if len(fields) == 0 {
+		return "", Errorf(KindChecksum, "%v is empty", checksumUrl)
+	}
+	checksum := strings.ToLower(fields[0])
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha256.Size*2 {
+		return "", Errorf(KindChecksum, "%v doesn't hold a sha256", checksumUrl)
+	}
+	return checksum, nil
+}
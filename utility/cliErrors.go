@@ -0,0 +1,86 @@
+package utility
+
+import "fmt"
+
+// ErrorKind says what went wrong. Each kind has its own exit code so scripts can react to it.
+// The exit codes are listed in the README and must not change.
+type ErrorKind int
+
+const (
+	KindGeneral        ErrorKind = 1
+	KindUsage          ErrorKind = 2
+	KindInvalidInstall ErrorKind = 3
+	KindDownload       ErrorKind = 4
+	KindChecksum       ErrorKind = 5
+	KindBuild          ErrorKind = 6
+	KindCopy           ErrorKind = 7
+	KindRollback       ErrorKind = 8
+	KindUnpack         ErrorKind = 9
+	KindUnhealthy      ErrorKind = 10
+	KindMigration      ErrorKind = 11
+)
+
+// Code names the kind in json output.
+func (k ErrorKind) Code() string {
+	switch k {
+	case KindUsage:
+		return "usage"
+	case KindInvalidInstall:
+		return "invalid_installation"
+	case KindDownload:
+		return "download_failed"
+	case KindChecksum:
+		return "checksum_mismatch"
+	case KindBuild:
+		return "build_failed"
+	case KindCopy:
+		return "copy_failed"
+	case KindRollback:
+		return "rollback_failed"
+	case KindUnpack:
+		return "unpack_failed"
+	case KindUnhealthy:
+		return "unhealthy"
+	case KindMigration:
+		return "migration_failed"
+	}
+	return "error"
+}
+
+func (k ErrorKind) ExitStatus() int {
+	return int(k)
+}
+
+// Error is an error of a known kind. It deliberately doesn't implement cli.ExitCoder since the cli
+// would exit straight away and skip the json result.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// NewError gives err a kind. Errors that already have one keep it so the first failure decides the exit code.
+func NewError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+func Errorf(kind ErrorKind, format string, a ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, a...)}
+}
+
+// ErrorKindOf returns the kind of err. Errors without one are general errors.
+func ErrorKindOf(err error) ErrorKind {
+	if e, ok := err.(*Error); ok {
+		return e.Kind
+	}
+	return KindGeneral
+}
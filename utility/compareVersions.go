@@ -0,0 +1,51 @@
+package utility
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two dotted versions like 0.0.12, returning -1, 0 or 1. Parts are compared as numbers
+// when both are numbers and as text otherwise. Missing parts count as 0, except that a version with a text part
+// where the other has ended is a pre-release and comes first, ex: 1.0.0-beta before 1.0.0. A leading v is ignored.
+func CompareVersions(a string, b string) int {
+	aParts := versionParts(a)
+	bParts := versionParts(b)
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		aNum, aErr := strconv.Atoi(aPart)
+		bNum, bErr := strconv.Atoi(bPart)
+		switch {
+		case i >= len(aParts) && bErr != nil:
+			return 1
+		case i >= len(bParts) && aErr != nil:
+			return -1
+		case aErr == nil && bErr == nil && aNum < bNum:
+			return -1
+		case aErr == nil && bErr == nil && aNum > bNum:
+			return 1
+		case aErr == nil && bErr == nil:
+			continue
+		case aPart < bPart:
+			return -1
+		case aPart > bPart:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	return strings.FieldsFunc(version, func(r rune) bool {
+		return r == '.' || r == '-'
+	})
+}
@@ -0,0 +1,36 @@
+package utility
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"0.0.1", "0.0.1", 0},
+		{"0.0.1", "0.0.2", -1},
+		{"0.0.2", "0.0.1", 1},
+		{"0.0.1", "0.0.12", -1},
+		{"0.0.12", "0.0.2", 1},
+		{"0.0.9", "0.0.10", -1},
+		{"1.0", "1.0.0", 0},
+		{"1", "1.0.1", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"v1.2.3", "1.2.3", 0},
+		{" 1.2.3 ", "1.2.3", 0},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.0", "1.0.0-beta", 1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.10", -1},
+		{"1.0.0-beta", "1.0.1", -1},
+		{"", "0", 0},
+		{"", "0.0.1", -1},
+	}
+
+	for _, test := range tests {
+		if got := CompareVersions(test.a, test.b); got != test.want {
+			t.Errorf("CompareVersions(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -14,6 +14,7 @@ type CopyContext struct {
 	Destination string
 	Verbose     bool
 	Ignore      []string
+	progress    ProgressReporter
 }
 
 func Copy(source string, dest string, hardCopy bool, verbose bool, ignore ...string) error {
@@ -86,6 +87,15 @@ func copyDir(source string, dest string, hardCopy bool, verbose bool, ignore ...
 		return err
 	}
 
+	// verbose output lists every file instead
+	cc.progress = NoProgress
+	if !cc.Verbose {
+		total := cc.countFiles()
+		cc.progress = NewProgress("Copying "+filepath.Base(cc.Source), ProgressFiles)
+		cc.progress.SetTotal(total)
+	}
+	defer cc.progress.Done()
+
 	err = filepath.Walk(cc.Source, cc.copyDirWalk)
 	if err != nil {
 		return err
@@ -126,8 +136,33 @@ func (cc *CopyContext) copyDirWalk(src string, info os.FileInfo, err error) erro
 	}
 
 	// otherwise copy file
-	return copyFile(src, dst, cc.Verbose)
+	err = copyFile(src, dst, cc.Verbose)
+	if err != nil {
+		return err
+	}
+	cc.progress.Add(1)
+
+	return nil
+}
 
+// countFiles counts the files that will be copied. Unreadable files are left for the copy to report.
+func (cc *CopyContext) countFiles() int64 {
+	var count int64
+	filepath.Walk(cc.Source, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		for _, ignoreFile := range cc.Ignore {
+			if ignoreRegex, err := regexp.Compile(ignoreFile); err != nil || ignoreRegex.MatchString(src) {
+				return nil
+			}
+		}
+		if !info.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
 }
 
 func copyFile(src, dst string, verbose bool) error {
@@ -153,6 +188,7 @@ func copyFile(src, dst string, verbose bool) error {
 	if verbose {
 		fmt.Printf("Copied %v to %v\n", src, dst)
 	}
+	Emit(EventFileCopied, "", FileCopiedData{Source: src, Destination: dst})
 	return nil
 }
 
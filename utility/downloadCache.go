@@ -0,0 +1,428 @@
+package utility
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const cacheDirEnv = "GCM_CACHE_DIR"
+const cacheBlobsDir = "blobs"
+const cacheIndexFile = "index.json"
+const cacheLockFile = "index.lock"
+const cacheLockTimeout = 10 * time.Second
+const cacheLockStale = time.Minute
+
+// CacheEntry describes one cached download. The file itself is stored by its checksum so a
+// download is kept once however many urls point at it.
+type CacheEntry struct {
+	Url          string    `json:"url"`
+	Checksum     string    `json:"checksum"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Downloaded   time.Time `json:"downloaded"`
+	LastUsed     time.Time `json:"lastUsed"`
+}
+
+type DownloadCache struct {
+	Dir     string
+	Entries []*CacheEntry
+	// urls changed or removed since the index was read, applied to the index on disk when saving
+	changed map[string]bool
+	removed map[string]bool
+}
+
+var offline bool
+
+// SetOffline makes downloads fail unless they are cached.
+func SetOffline(o bool) {
+	offline = o
+}
+
+// CacheDir is where downloads are cached. GCM_CACHE_DIR overrides the user cache dir.
+func CacheDir() (string, error) {
+	if dir := os.Getenv(cacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userCacheDir, "gcm", "downloads"), nil
+}
+
+func OpenDownloadCache() (*DownloadCache, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Join(dir, cacheBlobsDir), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &DownloadCache{Dir: dir}
+	cache.Entries, err = cache.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+func (dc *DownloadCache) readIndex() ([]*CacheEntry, error) {
+	raw, err := ioutil.ReadFile(filepath.Join(dc.Dir, cacheIndexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*CacheEntry
+	err = json.Unmarshal(raw, &entries)
+	if err != nil {
+		// a broken index only costs a download
+		fmt.Printf("Ignoring unreadable download cache index: %v\n", err.Error())
+		return nil, nil
+	}
+	return entries, nil
+}
+
+// lock keeps other gcm processes from writing the index until the returned func is called.
+func (dc *DownloadCache) lock() (func(), error) {
+	lockFile := filepath.Join(dc.Dir, cacheLockFile)
+	deadline := time.Now().Add(cacheLockTimeout)
+	for {
+		f, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { _ = os.Remove(lockFile) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		// a gcm that died while holding the lock leaves it behind
+		if info, err := os.Stat(lockFile); err == nil && time.Since(info.ModTime()) > cacheLockStale {
+			_ = os.Remove(lockFile)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %v", lockFile)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Save writes the index. Other gcm processes may have changed it since it was read, so under a lock the index
+// is read again and only the entries changed or removed here are applied to it. It is written through a temp
+// file so a crash never leaves half an index behind.
+func (dc *DownloadCache) Save() error {
+	unlock, err := dc.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return dc.save()
+}
+
+// save must be called with the lock held.
+func (dc *DownloadCache) save() error {
+	onDisk, err := dc.readIndex()
+	if err != nil {
+		return err
+	}
+
+	var merged []*CacheEntry
+	for _, entry := range dc.Entries {
+		if dc.changed[entry.Url] {
+			merged = append(merged, entry)
+		}
+	}
+	for _, entry := range onDisk {
+		if !dc.changed[entry.Url] && !dc.removed[entry.Url] {
+			merged = append(merged, entry)
+		}
+	}
+	dc.Entries = merged
+	dc.changed = nil
+	dc.removed = nil
+
+	raw, err := json.MarshalIndent(dc.Entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(dc.Dir, cacheIndexFile+"-")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(raw)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	err = os.Rename(tmpFile.Name(), filepath.Join(dc.Dir, cacheIndexFile))
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+func (dc *DownloadCache) BlobPath(checksum string) string {
+	return filepath.Join(dc.Dir, cacheBlobsDir, checksum)
+}
+
+// Lookup finds an entry by checksum when one is given and by url otherwise. Entries whose file is missing are ignored.
+func (dc *DownloadCache) Lookup(url string, checksum string) *CacheEntry {
+	for _, entry := range dc.Entries {
+		if checksum != "" && entry.Checksum != checksum {
+			continue
+		}
+		if checksum == "" && entry.Url != url {
+			continue
+		}
+		if _, err := os.Stat(dc.BlobPath(entry.Checksum)); err == nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (dc *DownloadCache) put(entry *CacheEntry) {
+	dc.markChanged(entry)
+	for i, existing := range dc.Entries {
+		if existing.Url == entry.Url {
+			dc.Entries[i] = entry
+			return
+		}
+	}
+	dc.Entries = append(dc.Entries, entry)
+}
+
+func (dc *DownloadCache) markChanged(entry *CacheEntry) {
+	if dc.changed == nil {
+		dc.changed = make(map[string]bool)
+	}
+	dc.changed[entry.Url] = true
+}
+
+// Remove drops entries and deletes files no remaining entry uses.
+func (dc *DownloadCache) Remove(remove func(entry *CacheEntry) bool) ([]*CacheEntry, error) {
+	unlock, err := dc.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var kept, removed []*CacheEntry
+	if dc.removed == nil {
+		dc.removed = make(map[string]bool)
+	}
+	for _, entry := range dc.Entries {
+		if remove(entry) {
+			removed = append(removed, entry)
+			dc.removed[entry.Url] = true
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	dc.Entries = kept
+
+	// entries other gcm processes added since the cache was opened keep their files too
+	err = dc.save()
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := make(map[string]bool)
+	for _, entry := range dc.Entries {
+		inUse[entry.Checksum] = true
+	}
+	blobs, err := ioutil.ReadDir(filepath.Join(dc.Dir, cacheBlobsDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		if !inUse[blob.Name()] {
+			_ = os.Remove(filepath.Join(dc.Dir, cacheBlobsDir, blob.Name()))
+		}
+	}
+
+	return removed, nil
+}
+
+// SortedEntries returns the entries with the most recently used first.
+func (dc *DownloadCache) SortedEntries() []*CacheEntry {
+	entries := append([]*CacheEntry{}, dc.Entries...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries
+}
+
+// FetchFile puts the file at downloadUrl in dest, downloading it only when the cache doesn't already hold it.
+// A checksum, when given, is verified and lets a file cached under another url be used. Cached files for
+// urls whose content can change, like the current release, are revalidated with the server unless offline.
+func FetchFile(dest string, downloadUrl string, checksum string) error {
+	cache, err := OpenDownloadCache()
+	if err != nil {
+		if offline {
+			return Errorf(KindDownload, "download cache unavailable while offline: %v", err.Error())
+		}
+		fmt.Printf("Download cache unavailable: %v. Downloading directly.\n", err.Error())
+		return downloadAndVerify(dest, downloadUrl, checksum)
+	}
+
+	entry := cache.Lookup(downloadUrl, checksum)
+	if offline {
+		if entry == nil {
+			return Errorf(KindDownload, "%v isn't cached and gcm is offline", downloadUrl)
+		}
+		fmt.Printf("Using cached %v\n", downloadUrl)
+		return cache.use(entry, dest)
+	}
+
+	// a matching checksum can't be stale
+	if entry != nil && checksum != "" {
+		fmt.Printf("Using cached %v\n", downloadUrl)
+		return cache.use(entry, dest)
+	}
+
+	head := headUrl(downloadUrl)
+	if entry != nil && head != nil && entry.matches(head) {
+		fmt.Printf("Using cached %v\n", downloadUrl)
+		return cache.use(entry, dest)
+	}
+
+	// download into the cache then copy out of it
+	tmpFile, err := ioutil.TempFile(cache.Dir, "download-")
+	if err != nil {
+		return err
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	err = downloadAndVerify(tmpFile.Name(), downloadUrl, checksum)
+	if err != nil {
+		return err
+	}
+
+	sum, err := FileChecksum(tmpFile.Name())
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(tmpFile.Name())
+	if err != nil {
+		return err
+	}
+	entry = &CacheEntry{
+		Url:        downloadUrl,
+		Checksum:   sum,
+		Size:       info.Size(),
+		Downloaded: time.Now(),
+	}
+	if head != nil {
+		entry.ETag = head.Header.Get("ETag")
+		entry.LastModified = head.Header.Get("Last-Modified")
+	}
+
+	// add the file and its entry together so a 'gcm cache clean' running meanwhile doesn't delete the file
+	unlock, err := cache.lock()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFile.Name(), cache.BlobPath(sum))
+	if err == nil {
+		cache.put(entry)
+		err = cache.save()
+	}
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	return cache.use(entry, dest)
+}
+
+// use copies a cached file to dest, creating dest's directory like a direct download would.
+func (dc *DownloadCache) use(entry *CacheEntry, dest string) error {
+	err := os.MkdirAll(filepath.Dir(dest), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = copyFileContents(dc.BlobPath(entry.Checksum), dest)
+	if err != nil {
+		return err
+	}
+
+	entry.LastUsed = time.Now()
+	dc.markChanged(entry)
+	err = dc.Save()
+	if err != nil {
+		fmt.Printf("Error saving download cache index: %v\n", err.Error())
+	}
+	return nil
+}
+
+// matches reports whether the server still has the cached file. Without an ETag or Last-Modified to go by the
+// cached file is assumed to be stale.
+func (entry *CacheEntry) matches(head *http.Response) bool {
+	if head.ContentLength >= 0 && head.ContentLength != entry.Size {
+		return false
+	}
+	if etag := head.Header.Get("ETag"); etag != "" {
+		return etag == entry.ETag
+	}
+	if lastModified := head.Header.Get("Last-Modified"); lastModified != "" {
+		return lastModified == entry.LastModified
+	}
+	return false
+}
+
+func headUrl(downloadUrl string) *http.Response {
+	req, err := http.NewRequest(http.MethodHead, downloadUrl, nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := DownloadRequest(req)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return resp
+}
+
+func downloadAndVerify(dest string, downloadUrl string, checksum string) error {
+	err := DownloadFile(dest, downloadUrl)
+	if err != nil {
+		return NewError(KindDownload, err)
+	}
+
+	if checksum == "" {
+		return nil
+	}
+	sum, err := FileChecksum(dest)
+	if err != nil {
+		return err
+	}
+	if sum != checksum {
+		_ = os.Remove(dest)
+		return Errorf(KindChecksum, "checksum of %v is %v but should be %v", downloadUrl, sum, checksum)
+	}
+	return nil
+}
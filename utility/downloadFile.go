@@ -1,41 +1,223 @@
 package utility
 
 import (
-	"github.com/cavaliercoder/grab"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"github.com/cavaliercoder/grab"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"time"
 )
 
+// DownloadOptions control how files are downloaded. Proxies are taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+type DownloadOptions struct {
+	// Retries is how many times a failed download is tried again
+	Retries int
+	// Timeout limits connecting, waiting for a response and how long a download may stall
+	Timeout time.Duration
+	// CACert is a PEM bundle trusted on top of the system's certificates
+	CACert string
+	// Username and Password are sent as basic auth. Token is sent as a bearer token instead.
+	Username string
+	Password string
+	Token    string
+}
+
+const downloadMinBackoff = time.Second
+const downloadMaxBackoff = 30 * time.Second
+
+var downloadOptions = DownloadOptions{
+	Retries: 3,
+	Timeout: 30 * time.Second,
+}
+
+var ErrDownloadCancelled = errors.New("download cancelled")
+
+func SetDownloadOptions(options DownloadOptions) {
+	downloadOptions = options
+}
+
+// newDownloadClient returns an http client for downloads. It has no overall timeout since releases
+// can take a while. Stalled downloads are caught by DownloadFile instead.
+func newDownloadClient() (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   downloadOptions.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   downloadOptions.Timeout,
+		ResponseHeaderTimeout: downloadOptions.Timeout,
+	}
+
+	if downloadOptions.CACert != "" {
+		pem, err := ioutil.ReadFile(downloadOptions.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA bundle %v: %v", downloadOptions.CACert, err.Error())
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %v", downloadOptions.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// authorizeDownload adds the configured credentials to req.
+func authorizeDownload(req *http.Request) {
+	switch {
+	case downloadOptions.Token != "":
+		req.Header.Set("Authorization", "Bearer "+downloadOptions.Token)
+	case downloadOptions.Username != "":
+		req.SetBasicAuth(downloadOptions.Username, downloadOptions.Password)
+	}
+}
+
+// DownloadRequest sends a small request, like fetching a version list, with the download settings.
+func DownloadRequest(req *http.Request) (*http.Response, error) {
+	client, err := newDownloadClient()
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = downloadOptions.Timeout
+	authorizeDownload(req)
+	return client.Do(req)
+}
+
+// DownloadFile downloads downloadUrl to filepath. Failed attempts are retried with an exponential backoff and
+// pick up where they left off when the server supports it. Ctrl-C stops the download and removes the partial file.
 func DownloadFile(filepath string, downloadUrl string) (err error) {
 
-	client := grab.NewClient()
-	req, _ := grab.NewRequest(filepath, downloadUrl)
+	client, err := newDownloadClient()
+	if err != nil {
+		return err
+	}
 
-	resp := client.Do(req)
+	// cancel on ctrl-c
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	progress := NewDownloadProgress(downloadUrl)
+	defer progress.Done()
+
+	backoff := downloadMinBackoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = downloadAttempt(ctx, client, filepath, downloadUrl, progress)
+		if err == nil {
+			return nil
+		}
 
+		if ctx.Err() != nil {
+			_ = os.Remove(filepath)
+			return ErrDownloadCancelled
+		}
+		if !retry || attempt >= downloadOptions.Retries {
+			return err
+		}
+
+		fmt.Printf("Download failed: %v. Retrying in %v (%v of %v)...\n", err.Error(), backoff, attempt+1, downloadOptions.Retries)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			_ = os.Remove(filepath)
+			return ErrDownloadCancelled
+		}
+		backoff *= 2
+		if backoff > downloadMaxBackoff {
+			backoff = downloadMaxBackoff
+		}
+	}
+}
+
+// downloadAttempt makes one attempt at a download and reports whether it is worth trying again if it fails.
+func downloadAttempt(ctx context.Context, client *http.Client, filepath string, downloadUrl string, progress ProgressReporter) (bool, error) {
+	attemptCtx, cancelAttempt := context.WithCancel(ctx)
+	defer cancelAttempt()
+
+	req, err := grab.NewRequest(filepath, downloadUrl)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(attemptCtx)
+	authorizeDownload(req.HTTPRequest)
+
+	grabClient := grab.NewClient()
+	grabClient.HTTPClient = client
+	resp := grabClient.Do(req)
 
 	// start Progress loop
-	t := time.NewTicker(500 * time.Millisecond)
+	t := time.NewTicker(100 * time.Millisecond)
 	defer t.Stop()
 
+	stalled := false
+	lastBytes := int64(-1)
+	lastChange := time.Now()
 Loop:
 	for {
 		select {
 		case <-t.C:
-			fmt.Printf("	transferred %v /%v bytes (%.2f%%)\n",
-				resp.BytesComplete(),
-				resp.Size,
-				100*resp.Progress())
+			progress.SetTotal(resp.Size)
+			progress.Set(resp.BytesComplete())
+
+			// give up on a download that stopped moving
+			if resp.BytesComplete() != lastBytes {
+				lastBytes = resp.BytesComplete()
+				lastChange = time.Now()
+			} else if downloadOptions.Timeout > 0 && time.Since(lastChange) > downloadOptions.Timeout {
+				stalled = true
+				cancelAttempt()
+			}
 
 		case <-resp.Done:
+			progress.SetTotal(resp.Size)
+			progress.Set(resp.BytesComplete())
 			break Loop
 		}
 	}
 
 	// check errors
+	if stalled {
+		return true, fmt.Errorf("no data received for %v", downloadOptions.Timeout)
+	}
 	if err := resp.Err(); err != nil {
-		return err
+		return retryableResponse(resp.HTTPResponse), err
 	}
 
-	return nil
-}
\ No newline at end of file
+	return false, nil
+}
+
+// retryableResponse reports whether a failed request may succeed later. Requests that got no response may.
+func retryableResponse(resp *http.Response) bool {
+	if resp == nil {
+		return true
+	}
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,347 @@
+package utility
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExtractLimits guard against archives that expand into far more than they look like.
+type ExtractLimits struct {
+	MaxFiles     int
+	MaxFileSize  int64
+	MaxTotalSize int64
+}
+
+var DefaultExtractLimits = ExtractLimits{
+	MaxFiles:     100000,
+	MaxFileSize:  1 << 30,
+	MaxTotalSize: 4 << 30,
+}
+
+// extractor writes archive entries into dest. Every entry must stay inside dest, symlinks included,
+// and the archive as a whole must stay within the limits.
+type extractor struct {
+	dest     string
+	limits   ExtractLimits
+	progress ProgressReporter
+
+	files   int
+	written int64
+	dirs    map[string]extractedDir
+	links   []string
+}
+
+type extractedDir struct {
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newExtractor(dest string, limits ExtractLimits, progress ProgressReporter) (*extractor, error) {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(dest, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &extractor{
+		dest:     dest,
+		limits:   limits,
+		progress: progress,
+		dirs:     make(map[string]extractedDir),
+	}, nil
+}
+
+// path turns an entry name into a path inside dest. Absolute names and names that climb out of dest are refused.
+func (e *extractor) path(name string) (string, error) {
+	slashed := strings.Replace(name, "\\", "/", -1)
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry %v has an absolute path", name)
+	}
+	for _, part := range strings.Split(slashed, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("archive entry %v points outside the destination", name)
+		}
+	}
+
+	path := filepath.Join(e.dest, filepath.FromSlash(slashed))
+	if !e.inside(path) {
+		return "", fmt.Errorf("archive entry %v points outside the destination", name)
+	}
+
+	// links are only checked where they sit so nothing may be extracted through one
+	for dir := filepath.Dir(path); dir != e.dest && e.inside(dir); dir = filepath.Dir(dir) {
+		info, err := os.Lstat(dir)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %v is inside the symlink %v", name, dir)
+		}
+	}
+	return path, nil
+}
+
+func (e *extractor) inside(path string) bool {
+	rel, err := filepath.Rel(e.dest, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (e *extractor) countFile() error {
+	e.files++
+	if e.limits.MaxFiles > 0 && e.files > e.limits.MaxFiles {
+		return fmt.Errorf("archive has more than %v entries", e.limits.MaxFiles)
+	}
+	return nil
+}
+
+func (e *extractor) dir(name string, mode os.FileMode, modTime time.Time) error {
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	err = e.countFile()
+	if err != nil {
+		return err
+	}
+
+	// keep the directory writable so its contents can be extracted
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(path, mode.Perm()|0700)
+	if err != nil {
+		return err
+	}
+
+	// mode and time are set once everything inside has been written
+	e.dirs[path] = extractedDir{mode: mode.Perm(), modTime: modTime}
+	return nil
+}
+
+// file writes r to the entry's path. Sizes are counted as the data is written so a header that
+// understates the size doesn't get around the limits.
+func (e *extractor) file(name string, r io.Reader, mode os.FileMode, modTime time.Time) error {
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	err = e.countFile()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	// replace rather than write through whatever is there, a symlink especially
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	limit := e.limits.MaxFileSize
+	if e.limits.MaxTotalSize > 0 && (limit <= 0 || e.limits.MaxTotalSize-e.written < limit) {
+		limit = e.limits.MaxTotalSize - e.written
+	}
+
+	var w io.Writer = f
+	if e.progress != nil {
+		w = &progressWriter{w: f, progress: e.progress}
+	}
+	var n int64
+	if limit > 0 {
+		n, err = io.Copy(w, io.LimitReader(r, limit+1))
+		if err == nil && n > limit {
+			err = fmt.Errorf("archive entry %v expands past the size limit", name)
+		}
+	} else {
+		n, err = io.Copy(w, r)
+	}
+	e.written += n
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// the umask may have taken bits away
+	err = os.Chmod(path, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if !modTime.IsZero() {
+		return os.Chtimes(path, modTime, modTime)
+	}
+	return nil
+}
+
+// symlink creates a link as long as its target stays inside dest.
+func (e *extractor) symlink(name string, target string) error {
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	err = e.countFile()
+	if err != nil {
+		return err
+	}
+
+	if filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("archive symlink %v points to the absolute path %v", name, target)
+	}
+	if !e.resolvesInside(filepath.Dir(path), target) {
+		return fmt.Errorf("archive symlink %v points outside the destination", name)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Symlink(target, path)
+	if err != nil {
+		return err
+	}
+	e.links = append(e.links, path)
+	return nil
+}
+
+// maxLinkHops is how many symlinks resolvesInside follows before giving up, like the os does for loops.
+const maxLinkHops = 40
+
+// resolvesInside follows target from dir the way the os would, through the links already extracted, and
+// reports whether it stays inside dest all along. Looking at the target on its own isn't enough: with s -> .
+// extracted, s/.. climbs out of dest although it looks like it stays put.
+func (e *extractor) resolvesInside(dir string, target string) bool {
+	path := dir
+	parts := strings.Split(strings.Replace(target, "\\", "/", -1), "/")
+	hops := 0
+	for i := 0; i < len(parts); i++ {
+		switch parts[i] {
+		case "", ".":
+			continue
+		case "..":
+			path = filepath.Dir(path)
+		default:
+			path = filepath.Join(path, parts[i])
+		}
+		if !e.inside(path) {
+			return false
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		hops++
+		if hops > maxLinkHops {
+			return false
+		}
+		link, err := os.Readlink(path)
+		if err != nil || filepath.IsAbs(link) || strings.HasPrefix(link, "/") {
+			return false
+		}
+		// carry on from the link's target
+		parts = append(strings.Split(strings.Replace(link, "\\", "/", -1), "/"), parts[i+1:]...)
+		path = filepath.Dir(path)
+		i = -1
+	}
+	return true
+}
+
+// link hard links an entry to one already extracted. The target has to be a regular file inside dest.
+func (e *extractor) link(name string, target string) error {
+	path, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	targetPath, err := e.path(target)
+	if err != nil {
+		return err
+	}
+	err = e.countFile()
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(targetPath)
+	if err != nil {
+		return fmt.Errorf("archive hard link %v points to %v which hasn't been extracted", name, target)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("archive hard link %v doesn't point to a regular file", name)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Link(targetPath, path)
+}
+
+// checkLinks checks the extracted links again since one extracted later can change where an earlier one leads.
+// Those that now lead outside dest are removed. It also has to run when extracting fails half way.
+func (e *extractor) checkLinks() error {
+	var escaped error
+	for _, path := range e.links {
+		target, err := os.Readlink(path)
+		if err != nil {
+			// replaced by a later entry
+			continue
+		}
+		if !e.resolvesInside(filepath.Dir(path), target) {
+			_ = os.Remove(path)
+			if escaped == nil {
+				rel, _ := filepath.Rel(e.dest, path)
+				escaped = fmt.Errorf("archive symlink %v points outside the destination", filepath.ToSlash(rel))
+			}
+		}
+	}
+	return escaped
+}
+
+// finish sets directory modes and times now that nothing else will be written into them.
+func (e *extractor) finish() error {
+	err := e.checkLinks()
+	if err != nil {
+		return err
+	}
+
+	for path, dir := range e.dirs {
+		err = os.Chmod(path, dir.mode)
+		if err != nil {
+			return err
+		}
+		if !dir.modTime.IsZero() {
+			err = os.Chtimes(path, dir.modTime, dir.modTime)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
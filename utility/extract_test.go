@@ -0,0 +1,335 @@
+package utility
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testEntry is one entry of an archive crafted for a test. Only one of body, dir, symlink and hardlink is used.
+type testEntry struct {
+	name     string
+	body     string
+	dir      bool
+	symlink  string
+	hardlink string
+}
+
+func writeTestZip(t *testing.T, path string, entries []testEntry) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, entry := range entries {
+		hdr := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		body := entry.body
+		switch {
+		case entry.dir:
+			hdr.SetMode(os.ModeDir | 0755)
+		case entry.symlink != "":
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = entry.symlink
+		case entry.hardlink != "":
+			t.Fatalf("zip can't hold the hard link %v", entry.name)
+		default:
+			hdr.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTar(t *testing.T, path string, entries []testEntry) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.body))}
+		switch {
+		case entry.dir:
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+		case entry.symlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Mode, hdr.Size = tar.TypeSymlink, entry.symlink, 0777, 0
+		case entry.hardlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, entry.hardlink, 0
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			_, err = tw.Write([]byte(entry.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hasLinks(entries []testEntry) (symlinks bool, hardlinks bool) {
+	for _, entry := range entries {
+		symlinks = symlinks || entry.symlink != ""
+		hardlinks = hardlinks || entry.hardlink != ""
+	}
+	return symlinks, hardlinks
+}
+
+// extractTest extracts entries as both a zip and a tar, unless they hold hard links which only tar can. Nothing
+// may ever be written next to dest.
+func extractTest(t *testing.T, entries []testEntry, limits ExtractLimits, check func(t *testing.T, dest string, err error)) {
+	symlinks, hardlinks := hasLinks(entries)
+	if symlinks && runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on windows")
+	}
+
+	formats := map[string]func(*testing.T, string, []testEntry){"zip": writeTestZip, "tar": writeTestTar}
+	for format, write := range formats {
+		if hardlinks && format == "zip" {
+			continue
+		}
+		t.Run(format, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gcm-extract-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			archive := filepath.Join(dir, "archive."+format)
+			write(t, archive, entries)
+			dest := filepath.Join(dir, "dest")
+			err = ExtractArchiveWithLimits(archive, dest, limits)
+			check(t, dest, err)
+
+			infos, readErr := ioutil.ReadDir(dir)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			for _, info := range infos {
+				if info.Name() != "dest" && info.Name() != filepath.Base(archive) {
+					t.Errorf("%v was written outside the destination", info.Name())
+				}
+			}
+		})
+	}
+}
+
+func expectError(contains string) func(t *testing.T, dest string, err error) {
+	return func(t *testing.T, dest string, err error) {
+		if err == nil {
+			t.Fatalf("expected an error containing %q", contains)
+		}
+		if !strings.Contains(err.Error(), contains) {
+			t.Fatalf("expected an error containing %q, got %v", contains, err)
+		}
+		if ErrorKindOf(err) != KindUnpack {
+			t.Errorf("expected an unpack error, got kind %v", ErrorKindOf(err))
+		}
+	}
+}
+
+func expectFile(name string, body string) func(t *testing.T, dest string, err error) {
+	return func(t *testing.T, dest string, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != body {
+			t.Errorf("%v holds %q, expected %q", name, raw, body)
+		}
+	}
+}
+
+func TestExtractPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+		check   func(t *testing.T, dest string, err error)
+	}{
+		{
+			name:    "plain files",
+			entries: []testEntry{{name: "content/", dir: true}, {name: "content/a.txt", body: "a"}, {name: "gocms", body: "bin"}},
+			check:   expectFile("content/a.txt", "a"),
+		},
+		{
+			name:    "zip slip",
+			entries: []testEntry{{name: "../evil", body: "x"}},
+			check:   expectError("outside the destination"),
+		},
+		{
+			name:    "zip slip in the middle",
+			entries: []testEntry{{name: "content/../../evil", body: "x"}},
+			check:   expectError("outside the destination"),
+		},
+		{
+			name:    "zip slip with backslashes",
+			entries: []testEntry{{name: "..\\evil", body: "x"}},
+			check:   expectError("outside the destination"),
+		},
+		{
+			name:    "absolute path",
+			entries: []testEntry{{name: "/tmp/evil", body: "x"}},
+			check:   expectError("absolute path"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extractTest(t, test.entries, DefaultExtractLimits, test.check)
+		})
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+		check   func(t *testing.T, dest string, err error)
+	}{
+		{
+			name:    "symlink inside",
+			entries: []testEntry{{name: "lib/a", body: "a"}, {name: "link", symlink: "lib/a"}},
+			check:   expectFile("link", "a"),
+		},
+		{
+			name:    "symlink climbing back inside",
+			entries: []testEntry{{name: "lib/a", body: "a"}, {name: "sub/link", symlink: "../lib/a"}},
+			check:   expectFile("sub/link", "a"),
+		},
+		{
+			name:    "symlink outside",
+			entries: []testEntry{{name: "link", symlink: "../outside"}},
+			check:   expectError("outside the destination"),
+		},
+		{
+			name:    "absolute symlink",
+			entries: []testEntry{{name: "link", symlink: "/etc/passwd"}},
+			check:   expectError("absolute path"),
+		},
+		{
+			name:    "chained symlinks",
+			entries: []testEntry{{name: "s", symlink: "."}, {name: "x", symlink: "s/.."}},
+			check:   expectError("points outside the destination"),
+		},
+		{
+			name:    "chained symlinks in reverse",
+			entries: []testEntry{{name: "x", symlink: "s/.."}, {name: "s", symlink: "."}},
+			check: func(t *testing.T, dest string, err error) {
+				expectError("x points outside the destination")(t, dest, err)
+				if _, err := os.Lstat(filepath.Join(dest, "x")); !os.IsNotExist(err) {
+					t.Errorf("the escaping link was left behind")
+				}
+			},
+		},
+		{
+			name:    "chained symlinks in a subdirectory",
+			entries: []testEntry{{name: "a/", dir: true}, {name: "a/up", symlink: ".."}, {name: "x", symlink: "a/up/.."}},
+			check:   expectError("points outside the destination"),
+		},
+		{
+			name:    "symlink loop",
+			entries: []testEntry{{name: "a", symlink: "b"}, {name: "b", symlink: "a/c"}},
+			check:   expectError("points outside the destination"),
+		},
+		{
+			name:    "writing through a symlink",
+			entries: []testEntry{{name: "sub/", dir: true}, {name: "d", symlink: "sub"}, {name: "d/f", body: "x"}},
+			check:   expectError("inside the symlink"),
+		},
+		{
+			name:    "hard link",
+			entries: []testEntry{{name: "a", body: "a"}, {name: "b", hardlink: "a"}},
+			check:   expectFile("b", "a"),
+		},
+		{
+			name:    "hard link outside",
+			entries: []testEntry{{name: "b", hardlink: "../outside"}},
+			check:   expectError("outside the destination"),
+		},
+		{
+			name:    "hard link to a symlink",
+			entries: []testEntry{{name: "a", body: "a"}, {name: "l", symlink: "a"}, {name: "b", hardlink: "l"}},
+			check:   expectError("regular file"),
+		},
+		{
+			name:    "hard link to nothing",
+			entries: []testEntry{{name: "b", hardlink: "missing"}},
+			check:   expectError("hasn't been extracted"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extractTest(t, test.entries, DefaultExtractLimits, test.check)
+		})
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	tests := []struct {
+		name    string
+		entries []testEntry
+		limits  ExtractLimits
+		check   func(t *testing.T, dest string, err error)
+	}{
+		{
+			name:    "within the limits",
+			entries: []testEntry{{name: "a", body: big}, {name: "b", body: big}},
+			limits:  ExtractLimits{MaxFiles: 2, MaxFileSize: 100, MaxTotalSize: 200},
+			check:   expectFile("b", big),
+		},
+		{
+			name:    "too many files",
+			entries: []testEntry{{name: "a", body: "a"}, {name: "b", body: "b"}, {name: "c", body: "c"}},
+			limits:  ExtractLimits{MaxFiles: 2},
+			check:   expectError("more than"),
+		},
+		{
+			name:    "file too big",
+			entries: []testEntry{{name: "a", body: big}},
+			limits:  ExtractLimits{MaxFileSize: 99},
+			check:   expectError("size limit"),
+		},
+		{
+			name:    "too much in total",
+			entries: []testEntry{{name: "a", body: big}, {name: "b", body: big}},
+			limits:  ExtractLimits{MaxTotalSize: 150},
+			check:   expectError("limit"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extractTest(t, test.entries, test.limits, test.check)
+		})
+	}
+}
@@ -7,35 +7,32 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"time"
 )
 
 type WatchFileContext struct {
-	Verbose          bool
-	SourceBase       string
-	DestinationBase  string
-	IgnorePaths      []string
-	DoneChan         chan bool
-	ChangeTimeoutMap map[string]time.Time
-	Chmod            func(c *WatchFileContext, eventPath string)
-	Removed          func(c *WatchFileContext, eventPath string)
-	Create           func(c *WatchFileContext, eventPath string)
-	Rename           func(c *WatchFileContext, eventPath string)
-	Write            func(c *WatchFileContext, eventPath string)
+	Verbose         bool
+	SourceBase      string
+	DestinationBase string
+	IgnorePaths     []string
+	DoneChan        chan bool
+	Chmod           func(c *WatchFileContext, eventPath string)
+	Removed         func(c *WatchFileContext, eventPath string)
+	Create          func(c *WatchFileContext, eventPath string)
+	Rename          func(c *WatchFileContext, eventPath string)
+	Write           func(c *WatchFileContext, eventPath string)
 }
 
 func WatchFilesForCarbonCopy(src string, dest string, verbose bool, ignore ...string) {
 	wf := WatchFileContext{
-		Verbose:          verbose,
-		SourceBase:       src,
-		DestinationBase:  dest,
-		IgnorePaths:      ignore,
-		ChangeTimeoutMap: make(map[string]time.Time),
-		Rename:           deleteDestination,
-		Removed:          deleteDestination,
-		Create:           copySourceToDestination,
-		Write:            copySourceToDestination,
-		Chmod:            IgnoreDestination,
+		Verbose:         verbose,
+		SourceBase:      src,
+		DestinationBase: dest,
+		IgnorePaths:     ignore,
+		Rename:          deleteDestination,
+		Removed:         deleteDestination,
+		Create:          copySourceToDestination,
+		Write:           copySourceToDestination,
+		Chmod:           IgnoreDestination,
 	}
 
 	wf.Watch()
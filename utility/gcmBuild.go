@@ -0,0 +1,70 @@
+package utility
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"runtime"
+	"runtime/debug"
+)
+
+// GcmBuildInfo describes how the running gcm was built.
+type GcmBuildInfo struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	BuildDate  string `json:"buildDate"`
+	CommitDate string `json:"commitDate"`
+	GoVersion  string `json:"go"`
+}
+
+// CurrentGcmBuild returns the version, commit and build date injected by build.sh. A gcm built without them,
+// ex: with go get, reports the commit go recorded from version control instead. go only records when that commit
+// was made, so it is kept apart from the build date.
+func CurrentGcmBuild() GcmBuildInfo {
+	build := GcmBuildInfo{
+		Version:   config.GCM_VERSION,
+		Commit:    config.GCM_COMMIT,
+		BuildDate: config.GCM_BUILD_DATE,
+		GoVersion: runtime.Version(),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && build.Commit == "":
+				build.Commit = setting.Value
+			case setting.Key == "vcs.time" && config.GCM_COMMIT == "":
+				build.CommitDate = setting.Value
+			}
+		}
+	}
+	return build
+}
+
+// String is the version followed by the short commit and its dates when they are known.
+// ex: 0.0.12 (commit 1a2b3c4, built 2017-06-01T12:00:00Z)
+func (b GcmBuildInfo) String() string {
+	details := ""
+	if b.Commit != "" {
+		commit := b.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		details = "commit " + commit
+	}
+	if b.CommitDate != "" {
+		if details != "" {
+			details += ", "
+		}
+		details += "committed " + b.CommitDate
+	}
+	if b.BuildDate != "" {
+		if details != "" {
+			details += ", "
+		}
+		details += "built " + b.BuildDate
+	}
+	if details == "" {
+		return b.Version
+	}
+	return fmt.Sprintf("%v (%v)", b.Version, details)
+}
@@ -0,0 +1,78 @@
+package utility
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/utility/utility_os"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+var ErrReloadNotSupported = errors.New("plugin reload is not supported by this gocms")
+
+// GoCMSUrl returns the local url of the gocms installed in installDir using the port from its .env file.
+func GoCMSUrl(installDir string) string {
+	port := config.GOCMS_DEFAULT_PORT
+
+	env, err := ParseEnvFile(filepath.Join(ActiveInstallDir(installDir), config.ENV_FILE))
+	if err == nil && env[config.GOCMS_PORT_ENV] != "" {
+		port = env[config.GOCMS_PORT_ENV]
+	}
+
+	return fmt.Sprintf("http://localhost:%v", port)
+}
+
+// ReloadPlugin asks a running gocms to restart a single plugin process from its binary on disk.
+// ErrReloadNotSupported is returned when gocms doesn't expose the reload endpoint.
+func ReloadPlugin(goCMSUrl string, pluginId string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	reloadUrl := goCMSUrl + fmt.Sprintf(config.GOCMS_RELOAD_PLUGIN_PATH, url.PathEscape(pluginId))
+	resp, err := client.Post(reloadUrl, "application/json", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		return ErrReloadNotSupported
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("gocms responded with %v", resp.Status)
+	}
+
+	return nil
+}
+
+// FindGoCMS looks for a gocms running in siteDir. One started with 'gcm run' is found through its pid file
+// and the pid returned is that of gcm, which stops gocms along with itself. Otherwise processes running one
+// of the installation's gocms binaries are looked for.
+func FindGoCMS(siteDir string) (pid int, managed bool, found bool) {
+	if pid, running := RunningPid(filepath.Join(siteDir, config.PID_FILE)); running {
+		return pid, true, true
+	}
+
+	binaries := []string{filepath.Join(siteDir, config.BINARY_FILE)}
+	if IsReleaseLayout(siteDir) {
+		releases, _ := ListReleases(siteDir)
+		for _, release := range releases {
+			binaries = append(binaries, filepath.Join(ReleaseDir(siteDir, release), config.BINARY_FILE))
+		}
+	}
+	for _, binary := range binaries {
+		exe, err := filepath.Abs(binary)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		if pids := utility_os.Find_pids_by_exe(exe); len(pids) > 0 {
+			return pids[0], false, true
+		}
+	}
+	return 0, false, false
+}
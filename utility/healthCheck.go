@@ -0,0 +1,162 @@
+package utility
+
+import (
+	"context"
+	"fmt"
+	"github.com/gocms-io/gcm/models"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type RouteCheck struct {
+	Route    *models.PluginManifestRoute
+	Method   string
+	Url      string
+	Status   int
+	Duration time.Duration
+	Err      error
+	Skipped  string
+}
+
+// Failed reports routes that couldn't be reached, aren't registered or returned a server error.
+// Client errors such as 401 still prove the route is being served.
+func (rc *RouteCheck) Failed() bool {
+	if rc.Skipped != "" {
+		return false
+	}
+	return rc.Err != nil || rc.Status == http.StatusNotFound || rc.Status >= 500
+}
+
+// WaitForReady polls healthUrl until it answers without a server error. It gives up once timeout passes or ctx is done.
+func WaitForReady(ctx context.Context, healthUrl string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	var lastErr error
+	for {
+		req, err := http.NewRequest(http.MethodGet, healthUrl, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return nil
+			}
+			lastErr = fmt.Errorf("%v responded with %v", healthUrl, resp.Status)
+		} else {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("not ready after %v: %v", timeout, lastErr)
+			}
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// CheckRoutes requests the url of each route a plugin declares from the running gocms. Only GET and HEAD
+// routes without parameters are requested so checking never changes any data.
+func CheckRoutes(ctx context.Context, goCMSUrl string, routes []*models.PluginManifestRoute) []*RouteCheck {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var checks []*RouteCheck
+	for _, route := range routes {
+		method := strings.ToUpper(route.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		check := &RouteCheck{
+			Route:  route,
+			Method: method,
+			Url:    RouteUrl(goCMSUrl, route.Url),
+		}
+		checks = append(checks, check)
+
+		if route.Url == "" {
+			check.Skipped = "no url declared"
+			continue
+		}
+		if method != http.MethodGet && method != http.MethodHead {
+			check.Skipped = "not a GET route"
+			continue
+		}
+		if strings.ContainsAny(route.Url, ":*{") {
+			check.Skipped = "has parameters"
+			continue
+		}
+
+		req, err := http.NewRequest(method, check.Url, nil)
+		if err != nil {
+			check.Err = err
+			continue
+		}
+
+		start := time.Now()
+		resp, err := client.Do(req.WithContext(ctx))
+		check.Duration = time.Since(start)
+		if err != nil {
+			check.Err = err
+			continue
+		}
+		resp.Body.Close()
+		check.Status = resp.StatusCode
+	}
+
+	return checks
+}
+
+// RouteUrl is where gocms serves a plugin endpoint. path is the url a route declares in the manifest. Its route
+// only names the group gocms registers the endpoint in, ex: public or admin, and isn't part of the path.
+func RouteUrl(goCMSUrl string, path string) string {
+	return strings.TrimRight(goCMSUrl, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// PrintRouteChecks prints a table of route results and returns how many failed.
+func PrintRouteChecks(pluginId string, checks []*RouteCheck) int {
+	failed := 0
+
+	fmt.Printf("Plugin %v:\n", pluginId)
+	if len(checks) == 0 {
+		fmt.Printf("  no routes declared\n")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, check := range checks {
+		result := fmt.Sprintf("%v", check.Status)
+		switch {
+		case check.Skipped != "":
+			result = "skipped (" + check.Skipped + ")"
+		case check.Err != nil:
+			result = "error: " + check.Err.Error()
+		}
+		if check.Failed() {
+			failed++
+			result += " FAILED"
+		}
+		fmt.Fprintf(w, "  %v\t%v\t%v\t%v\n", check.Route.Name, check.Method, check.Url, result)
+	}
+	w.Flush()
+
+	return failed
+}
+
+// PrintReadyBanner announces that gocms is up.
+func PrintReadyBanner(goCMSUrl string, took time.Duration) {
+	fmt.Printf("==================================================\n")
+	fmt.Printf(" GoCMS is ready after %v - %v\n", took.Round(time.Millisecond), time.Now().Format("03:04:05"))
+	fmt.Printf(" %v\n", goCMSUrl)
+	fmt.Printf("==================================================\n")
+}
@@ -0,0 +1,93 @@
+package utility
+
+import (
+	"context"
+	"github.com/gocms-io/gcm/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckRoutes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/hello":
+			w.WriteHeader(http.StatusOK)
+		case "/api/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		route   *models.PluginManifestRoute
+		url     string
+		status  int
+		skipped string
+		failed  bool
+	}{
+		{
+			name:   "url not route",
+			route:  &models.PluginManifestRoute{Name: "hello", Route: "public", Method: "GET", Url: "/api/hello"},
+			url:    server.URL + "/api/hello",
+			status: http.StatusOK,
+		},
+		{
+			name:   "server error",
+			route:  &models.PluginManifestRoute{Name: "broken", Route: "public", Url: "/api/broken"},
+			url:    server.URL + "/api/broken",
+			status: http.StatusInternalServerError,
+			failed: true,
+		},
+		{
+			name:   "not served",
+			route:  &models.PluginManifestRoute{Name: "missing", Route: "/api/hello", Url: "/api/missing"},
+			url:    server.URL + "/api/missing",
+			status: http.StatusNotFound,
+			failed: true,
+		},
+		{
+			name:    "parameters in the url",
+			route:   &models.PluginManifestRoute{Name: "item", Route: "public", Method: "GET", Url: "/api/items/:id"},
+			url:     server.URL + "/api/items/:id",
+			skipped: "has parameters",
+		},
+		{
+			name:    "not a GET route",
+			route:   &models.PluginManifestRoute{Name: "create", Route: "auth", Method: "post", Url: "/api/hello"},
+			url:     server.URL + "/api/hello",
+			skipped: "not a GET route",
+		},
+		{
+			name:    "no url",
+			route:   &models.PluginManifestRoute{Name: "nothing", Route: "/api/hello"},
+			url:     server.URL + "/",
+			skipped: "no url declared",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checks := CheckRoutes(context.Background(), server.URL, []*models.PluginManifestRoute{test.route})
+			if len(checks) != 1 {
+				t.Fatalf("got %v checks, expected 1", len(checks))
+			}
+			check := checks[0]
+			if check.Url != test.url {
+				t.Errorf("requested %v, expected %v", check.Url, test.url)
+			}
+			if check.Status != test.status {
+				t.Errorf("got status %v, expected %v", check.Status, test.status)
+			}
+			if check.Skipped != test.skipped {
+				t.Errorf("skipped %q, expected %q", check.Skipped, test.skipped)
+			}
+			if check.Failed() != test.failed {
+				t.Errorf("failed is %v, expected %v", check.Failed(), test.failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package utility
+
+import (
+	"encoding/json"
+	"github.com/gocms-io/gcm/config"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// InstallInfo is what gcm recorded when it installed a release, since not every release says its version.
+type InstallInfo struct {
+	Version   string    `json:"version,omitempty"`
+	Source    string    `json:"source"`
+	Installed time.Time `json:"installed"`
+}
+
+func WriteInstallInfo(installDir string, info InstallInfo) error {
+	raw, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(installDir, config.INSTALL_INFO_FILE), raw, 0644)
+}
+
+// ReadInstallInfo reads what was recorded for the active release of siteDir.
+func ReadInstallInfo(siteDir string) (*InstallInfo, error) {
+	raw, err := ioutil.ReadFile(filepath.Join(ActiveInstallDir(siteDir), config.INSTALL_INFO_FILE))
+	if err != nil {
+		return nil, err
+	}
+	var info InstallInfo
+	err = json.Unmarshal(raw, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// InstalledGoCMSVersion is the gocms version of the active release of siteDir. The release's own release.json
+// wins over what was recorded at install time. It is empty when neither says.
+func InstalledGoCMSVersion(siteDir string) string {
+	manifest, err := ParseReleaseManifest(filepath.Join(ActiveInstallDir(siteDir), config.RELEASE_MANIFEST))
+	if err == nil && manifest.Version != "" {
+		return manifest.Version
+	}
+	if info, err := ReadInstallInfo(siteDir); err == nil {
+		return info.Version
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package utility
+
+import (
+	"github.com/gocms-io/gcm/config"
+	"github.com/gocms-io/gcm/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// InstalledPlugins returns the manifests of the plugins installed in siteDir sorted by id. Plugin directories
+// without a manifest are skipped.
+func InstalledPlugins(siteDir string) ([]*models.PluginManifest, error) {
+	pluginsDir := filepath.Join(ActiveInstallDir(siteDir), config.CONTENT_DIR, config.PLUGINS_DIR)
+	pluginDirs, err := ioutil.ReadDir(pluginsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*models.PluginManifest
+	for _, pluginDir := range pluginDirs {
+		manifestPath := filepath.Join(pluginsDir, pluginDir.Name(), config.PLUGIN_MANIFEST)
+		if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+			continue
+		}
+		manifest, err := ParseManifest(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Id < manifests[j].Id
+	})
+	return manifests, nil
+}
@@ -0,0 +1,85 @@
+package utility
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+type JUnitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Suites   []*JUnitTestSuite `xml:"testsuite"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+}
+
+type JUnitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr"`
+	Cases     []*JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitMessage `xml:"failure,omitempty"`
+	Skipped   *JUnitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type JUnitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func NewJUnitTestSuite(name string, timestamp time.Time) *JUnitTestSuite {
+	return &JUnitTestSuite{
+		Name:      name,
+		Timestamp: timestamp.Format("2006-01-02T15:04:05"),
+	}
+}
+
+// AddCase adds a test case and keeps the suite counters up to date.
+func (ts *JUnitTestSuite) AddCase(tc *JUnitTestCase) {
+	ts.Cases = append(ts.Cases, tc)
+	ts.Tests++
+	if tc.Failure != nil {
+		ts.Failures++
+	}
+	if tc.Skipped != nil {
+		ts.Skipped++
+	}
+}
+
+// JUnitDuration formats a duration as the seconds JUnit reports expect.
+func JUnitDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnitReport writes the suites as a JUnit XML report.
+func WriteJUnitReport(fileUri string, suites []*JUnitTestSuite, total time.Duration) error {
+	report := JUnitTestSuites{
+		Suites: suites,
+		Time:   JUnitDuration(total),
+	}
+	for _, suite := range suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+	}
+
+	raw, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fileUri, append([]byte(xml.Header), raw...), 0644)
+}
@@ -0,0 +1,277 @@
+package utility
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	}
+	return "unknown"
+}
+
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LogDebug, nil
+	case "", "info":
+		return LogInfo, nil
+	case "warn", "warning":
+		return LogWarn, nil
+	case "error":
+		return LogError, nil
+	}
+	return LogInfo, fmt.Errorf("unknown log level '%v'. Use debug, info, warn or error", level)
+}
+
+// lines longer than this are written out in pieces rather than buffered forever
+const maxLogLineSize = 1024 * 1024
+
+// only the start of a line is searched for a level so messages that mention errors aren't promoted
+const logLevelSearchSize = 64
+
+var logLevelRegex = regexp.MustCompile(`(?i)\b(debug|trace|info|warn|warning|error|fatal|panic)\b`)
+
+var logTagColors = map[string]color.Attribute{
+	"gcm":       color.FgCyan,
+	"build":     color.FgYellow,
+	"gocms":     color.FgGreen,
+	"gocms:err": color.FgRed,
+}
+
+// LogMux writes lines from several sources to one output with each line tagged by its source.
+// Lines below Level are dropped from the output. The tee file receives every line, timestamped and without color.
+type LogMux struct {
+	Color      bool
+	Timestamps bool
+	Level      LogLevel
+
+	mu  sync.Mutex
+	out io.Writer
+	tee io.Writer
+}
+
+// NewLogMux returns a mux writing to out. Color is enabled when out is a terminal.
+func NewLogMux(out *os.File) *LogMux {
+	return &LogMux{
+		Color: isatty.IsTerminal(out.Fd()),
+		Level: LogInfo,
+		out:   out,
+	}
+}
+
+// Tee also writes every line to w.
+func (m *LogMux) Tee(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tee = w
+}
+
+// Stream returns a writer whose lines are tagged with tag. Lines without a recognizable level get level.
+func (m *LogMux) Stream(tag string, level LogLevel) *LogStream {
+	s := &LogStream{
+		mux:   m,
+		tag:   tag,
+		level: level,
+	}
+	s.lines = newLineWriter(s.writeLine)
+	return s
+}
+
+func (m *LogMux) Printf(tag string, level LogLevel, format string, a ...interface{}) {
+	for _, line := range strings.Split(strings.TrimRight(fmt.Sprintf(format, a...), "\n"), "\n") {
+		m.WriteLine(tag, level, line)
+	}
+}
+
+func (m *LogMux) WriteLine(tag string, level LogLevel, line string) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tee != nil {
+		fmt.Fprintf(m.tee, "%v [%v] %v\n", now.Format("2006-01-02 15:04:05.000"), tag, line)
+	}
+
+	if level < m.Level {
+		return
+	}
+
+	prefix := "[" + tag + "]"
+	if m.Color {
+		prefix = tagColor(tag).Sprint(prefix)
+		switch level {
+		case LogWarn:
+			line = logColor(color.FgYellow).Sprint(line)
+		case LogError:
+			line = logColor(color.FgRed).Sprint(line)
+		}
+	}
+	if m.Timestamps {
+		prefix = now.Format("15:04:05.000") + " " + prefix
+	}
+
+	fmt.Fprintf(m.out, "%v %v\n", prefix, line)
+}
+
+func tagColor(tag string) *color.Color {
+	if attr, ok := logTagColors[tag]; ok {
+		return logColor(attr)
+	}
+	if strings.HasPrefix(tag, "plugin:") {
+		return logColor(color.FgMagenta)
+	}
+	return logColor(color.FgWhite)
+}
+
+// logColor returns a color that is always applied. Whether to color at all is decided by the mux.
+func logColor(attr color.Attribute) *color.Color {
+	c := color.New(attr)
+	c.EnableColor()
+	return c
+}
+
+func detectLogLevel(line string, fallback LogLevel) LogLevel {
+	start := line
+	if len(start) > logLevelSearchSize {
+		start = start[:logLevelSearchSize]
+	}
+
+	switch strings.ToLower(logLevelRegex.FindString(start)) {
+	case "debug", "trace":
+		return LogDebug
+	case "info":
+		return LogInfo
+	case "warn", "warning":
+		return LogWarn
+	case "error", "fatal", "panic":
+		return LogError
+	}
+	return fallback
+}
+
+// LogStream splits whatever is written to it into lines and passes them to its mux.
+type LogStream struct {
+	// Route may return a different tag for a line. ex: plugin output that gocms passes through
+	Route func(line string) string
+
+	mux   *LogMux
+	tag   string
+	level LogLevel
+	lines *lineWriter
+}
+
+func (s *LogStream) Write(p []byte) (int, error) {
+	return s.lines.Write(p)
+}
+
+// Close writes out a trailing line that didn't end in a newline.
+func (s *LogStream) Close() error {
+	return s.lines.Close()
+}
+
+func (s *LogStream) writeLine(line string) {
+	tag := s.tag
+	if s.Route != nil {
+		tag = s.Route(line)
+	}
+
+	s.mux.WriteLine(tag, detectLogLevel(line, s.level), line)
+}
+
+// lineWriter calls onLine for every line written to it. There is no limit on line length apart from
+// very long lines being passed on in 1MB pieces.
+type lineWriter struct {
+	mu     sync.Mutex
+	buf    []byte
+	onLine func(line string)
+}
+
+func newLineWriter(onLine func(line string)) *lineWriter {
+	return &lineWriter{onLine: onLine}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(strings.TrimRight(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+
+	if len(w.buf) >= maxLogLineSize {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+
+	return len(p), nil
+}
+
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.onLine(strings.TrimRight(string(w.buf), "\r"))
+		w.buf = nil
+	}
+	return nil
+}
+
+// CaptureStdout sends everything written to os.Stdout to w until the returned func is called.
+// The returned func restores os.Stdout and waits for the captured output to be written.
+func CaptureStdout(w io.Writer) (func(), error) {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := os.Stdout
+	os.Stdout = pw
+
+	done := make(chan bool)
+	go func() {
+		io.Copy(w, r)
+		r.Close()
+		close(done)
+	}()
+
+	return func() {
+		os.Stdout = stdout
+		pw.Close()
+		<-done
+	}, nil
+}
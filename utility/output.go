@@ -0,0 +1,170 @@
+package utility
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const OutputText = "text"
+const OutputJSON = "json"
+
+// event types. See "JSON Output" in the README for the schema.
+const EventLog = "log"
+const EventProgress = "progress"
+const EventFileCopied = "file_copied"
+const EventBuildStarted = "build_started"
+const EventBuildFinished = "build_finished"
+const EventError = "error"
+const EventResult = "result"
+
+// Event is one line of json output.
+type Event struct {
+	Type    string      `json:"type"`
+	Time    string      `json:"time"`
+	Message string      `json:"message,omitempty"`
+	Code    string      `json:"code,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type ResultData struct {
+	Command string                 `json:"command"`
+	Ok      bool                   `json:"ok"`
+	Result  map[string]interface{} `json:"result,omitempty"`
+}
+
+// ProgressData counts bytes or, when Unit is files, files.
+type ProgressData struct {
+	Task    string  `json:"task"`
+	Url     string  `json:"url,omitempty"`
+	Unit    string  `json:"unit"`
+	Bytes   int64   `json:"bytes"`
+	Total   int64   `json:"total"`
+	Percent float64 `json:"percent"`
+}
+
+type FileCopiedData struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+type BuildStartedData struct {
+	Plugin string `json:"plugin"`
+	Binary string `json:"binary"`
+}
+
+type BuildFinishedData struct {
+	Plugin   string `json:"plugin"`
+	Binary   string `json:"binary"`
+	Ok       bool   `json:"ok"`
+	Duration int64  `json:"durationMs"`
+	Error    string `json:"error,omitempty"`
+}
+
+var output = struct {
+	mu       sync.Mutex
+	format   string
+	out      io.Writer
+	restore  func()
+	logLines *lineWriter
+	command  string
+	result   map[string]interface{}
+}{
+	format: OutputText,
+}
+
+// SetOutputFormat switches between text and json output. In json mode everything gcm would have printed
+// is turned into log events so stdout only ever holds one json event per line.
+func SetOutputFormat(format string, command string) error {
+	switch format {
+	case "", OutputText:
+		return nil
+	case OutputJSON:
+	default:
+		return fmt.Errorf("unknown output format '%v'. Use text or json", format)
+	}
+
+	output.mu.Lock()
+	output.format = OutputJSON
+	output.out = os.Stdout
+	output.command = command
+	output.mu.Unlock()
+
+	output.logLines = newLineWriter(func(line string) {
+		Emit(EventLog, line, nil)
+	})
+	restore, err := CaptureStdout(output.logLines)
+	if err != nil {
+		return err
+	}
+	output.restore = restore
+
+	return nil
+}
+
+func JSONOutput() bool {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	return output.format == OutputJSON
+}
+
+// Emit writes an event in json mode and does nothing otherwise.
+func Emit(eventType string, message string, data interface{}) {
+	emit(Event{Type: eventType, Message: message, Data: data})
+}
+
+func EmitError(code string, err error) {
+	emit(Event{Type: EventError, Message: err.Error(), Code: code})
+}
+
+// SetResult adds a value to the result event written when the command finishes.
+func SetResult(key string, value interface{}) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	if output.result == nil {
+		output.result = make(map[string]interface{})
+	}
+	output.result[key] = value
+}
+
+// FinishOutput writes any captured output that is left followed by the error, if there is one, and the result event.
+func FinishOutput(err error, code string) {
+	if !JSONOutput() {
+		return
+	}
+
+	output.restore()
+	output.logLines.Close()
+
+	if err != nil {
+		EmitError(code, err)
+	}
+
+	output.mu.Lock()
+	result := ResultData{
+		Command: output.command,
+		Ok:      err == nil,
+		Result:  output.result,
+	}
+	output.mu.Unlock()
+	Emit(EventResult, "", result)
+}
+
+func emit(event Event) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	if output.format != OutputJSON {
+		return
+	}
+
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	raw, err := json.Marshal(event)
+	if err != nil {
+		raw, _ = json.Marshal(Event{Type: EventError, Time: event.Time, Message: err.Error()})
+	}
+	output.out.Write(append(raw, '\n'))
+}
@@ -0,0 +1,46 @@
+package utility
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// ParseEnvFile reads KEY=VALUE pairs from a .env file. Blank lines, comments and an optional
+// leading "export" are skipped and surrounding quotes are removed from values.
+func ParseEnvFile(fileUri string) (map[string]string, error) {
+	env := make(map[string]string)
+
+	f, err := os.Open(fileUri)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
@@ -0,0 +1,27 @@
+package utility
+
+import (
+	"github.com/gocms-io/gcm/models"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+)
+
+func ParsePluginConfig(fileUri string) (*models.PluginConfig, error) {
+	var pluginConfig models.PluginConfig
+
+	// read file in
+	raw, err := ioutil.ReadFile(fileUri)
+	if err != nil {
+		log.Printf("Error reading raw plugin config file %s: %s\n", fileUri, err.Error())
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(raw, &pluginConfig)
+	if err != nil {
+		log.Printf("Error parsing plugin config file %s: %s\n", fileUri, err.Error())
+		return nil, err
+	}
+
+	return &pluginConfig, nil
+}
@@ -0,0 +1,23 @@
+package utility
+
+import (
+	"encoding/json"
+	"github.com/gocms-io/gcm/models"
+	"io/ioutil"
+)
+
+func ParseReleaseManifest(fileUri string) (*models.ReleaseManifest, error) {
+	var manifest models.ReleaseManifest
+
+	raw, err := ioutil.ReadFile(fileUri)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
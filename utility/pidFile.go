@@ -0,0 +1,69 @@
+package utility
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/utility/utility_os"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WritePidFile records the pid of gcm, not of gocms. gcm supervises gocms and stops it along with itself, so
+// signalling that pid is how gocms is stopped or restarted. It refuses to replace a pid file whose process is
+// still alive.
+func WritePidFile(pidFile string) error {
+	pid, err := ReadPidFile(pidFile)
+	if err == nil && pid != os.Getpid() && utility_os.Pid_exists(pid) {
+		return fmt.Errorf("gocms is already running with pid %v according to %v", pid, pidFile)
+	}
+
+	return ioutil.WriteFile(pidFile, []byte(fmt.Sprintf("%v\n", os.Getpid())), 0644)
+}
+
+func ReadPidFile(pidFile string) (int, error) {
+	raw, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid pid file %v: %v", pidFile, err.Error())
+	}
+
+	return pid, nil
+}
+
+// RunningPid returns the pid from a pid file if that process is still alive. Stale pid files are removed.
+func RunningPid(pidFile string) (int, bool) {
+	pid, err := ReadPidFile(pidFile)
+	if err != nil {
+		return 0, false
+	}
+
+	if !utility_os.Pid_exists(pid) {
+		_ = os.Remove(pidFile)
+		return 0, false
+	}
+
+	return pid, true
+}
+
+// StopPid asks the process to stop and waits up to timeout for it to exit.
+func StopPid(pid int, timeout time.Duration) error {
+	err := utility_os.Terminate_pid(pid)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for utility_os.Pid_exists(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("pid %v didn't stop within %v", pid, timeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package utility
+
+import (
+	"fmt"
+	"github.com/mattn/go-isatty"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ProgressUnit int
+
+const (
+	ProgressBytes ProgressUnit = iota
+	ProgressFiles
+)
+
+func (u ProgressUnit) String() string {
+	if u == ProgressFiles {
+		return "files"
+	}
+	return "bytes"
+}
+
+// ProgressReporter is told how far along a long running task is. How that is shown, if at all,
+// is up to the reporter.
+type ProgressReporter interface {
+	SetTotal(total int64)
+	Add(n int64)
+	Set(current int64)
+	Done()
+}
+
+type noProgress struct{}
+
+func (noProgress) SetTotal(total int64) {}
+func (noProgress) Add(n int64)          {}
+func (noProgress) Set(current int64)    {}
+func (noProgress) Done()                {}
+
+// NoProgress is a reporter that ignores everything.
+var NoProgress ProgressReporter = noProgress{}
+
+// nothing is shown for tasks that finish quicker than this
+const progressDelay = 500 * time.Millisecond
+
+const progressBarWidth = 30
+const progressBarInterval = 100 * time.Millisecond
+const progressLineInterval = 2 * time.Second
+const progressEventInterval = 500 * time.Millisecond
+
+var progressQuiet bool
+
+// SetQuiet turns all progress reporting off.
+func SetQuiet(quiet bool) {
+	progressQuiet = quiet
+}
+
+type progressMode int
+
+const (
+	progressModeBar progressMode = iota
+	progressModeLines
+	progressModeEvents
+)
+
+type progress struct {
+	label string
+	url   string
+	unit  ProgressUnit
+	mode  progressMode
+	out   io.Writer
+
+	mu         sync.Mutex
+	total      int64
+	current    int64
+	start      time.Time
+	lastRender time.Time
+	rendered   bool
+	lastWidth  int
+	done       bool
+}
+
+// NewProgress returns a reporter that draws a single updating bar on a terminal, prints a line every
+// few seconds when output is piped, writes progress events in json mode and does nothing with --quiet.
+func NewProgress(label string, unit ProgressUnit) ProgressReporter {
+	p := &progress{
+		label: label,
+		unit:  unit,
+		out:   os.Stdout,
+		start: time.Now(),
+	}
+
+	switch {
+	case progressQuiet:
+		return NoProgress
+	case JSONOutput():
+		p.mode = progressModeEvents
+	case isatty.IsTerminal(os.Stdout.Fd()):
+		p.mode = progressModeBar
+	default:
+		p.mode = progressModeLines
+	}
+
+	return p
+}
+
+// NewDownloadProgress reports the progress of downloading url.
+func NewDownloadProgress(url string) ProgressReporter {
+	p := NewProgress("Downloading "+url[strings.LastIndex(url, "/")+1:], ProgressBytes)
+	if download, ok := p.(*progress); ok {
+		download.url = url
+	}
+	return p
+}
+
+func (p *progress) SetTotal(total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+	p.render(false)
+}
+
+func (p *progress) Add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current += n
+	p.render(false)
+}
+
+func (p *progress) Set(current int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = current
+	p.render(false)
+}
+
+// Done shows the final state of a task that was being shown and ends the bar.
+func (p *progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	p.done = true
+
+	if !p.rendered {
+		return
+	}
+	p.render(true)
+	if p.mode == progressModeBar {
+		fmt.Fprintln(p.out)
+	}
+}
+
+func (p *progress) render(final bool) {
+	if p.done && !final {
+		return
+	}
+
+	now := time.Now()
+	if !final {
+		if now.Sub(p.start) < progressDelay {
+			return
+		}
+
+		interval := progressBarInterval
+		switch p.mode {
+		case progressModeLines:
+			interval = progressLineInterval
+		case progressModeEvents:
+			interval = progressEventInterval
+		}
+		if p.rendered && now.Sub(p.lastRender) < interval {
+			return
+		}
+	}
+	p.lastRender = now
+	p.rendered = true
+
+	switch p.mode {
+	case progressModeEvents:
+		Emit(EventProgress, "", ProgressData{
+			Task:    p.label,
+			Url:     p.url,
+			Unit:    p.unit.String(),
+			Bytes:   p.current,
+			Total:   p.total,
+			Percent: p.percent(),
+		})
+	case progressModeLines:
+		fmt.Fprintf(p.out, "%v: %v\n", p.label, p.status(now))
+	case progressModeBar:
+		line := fmt.Sprintf("%v %v %v", truncateLabel(p.label), p.bar(), p.status(now))
+		// pad over whatever is left of a longer previous line
+		padding := ""
+		if p.lastWidth > len(line) {
+			padding = strings.Repeat(" ", p.lastWidth-len(line))
+		}
+		p.lastWidth = len(line)
+		fmt.Fprintf(p.out, "\r%v%v", line, padding)
+	}
+}
+
+func (p *progress) percent() float64 {
+	if p.total <= 0 {
+		return 0
+	}
+	return 100 * float64(p.current) / float64(p.total)
+}
+
+func (p *progress) bar() string {
+	if p.total <= 0 {
+		return "[" + strings.Repeat("?", progressBarWidth) + "]"
+	}
+	filled := int(int64(progressBarWidth) * p.current / p.total)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "]"
+}
+
+// status describes how far along the task is along with its rate and, when the total is known, the time left.
+func (p *progress) status(now time.Time) string {
+	elapsed := now.Sub(p.start)
+	rate := float64(0)
+	if elapsed > 0 {
+		rate = float64(p.current) / elapsed.Seconds()
+	}
+
+	if p.total <= 0 {
+		return fmt.Sprintf("%v %v/s", p.amount(float64(p.current)), p.amount(rate))
+	}
+
+	status := fmt.Sprintf("%5.1f%% %v/%v %v/s", p.percent(), p.amount(float64(p.current)), p.amount(float64(p.total)), p.amount(rate))
+	if p.current < p.total && rate > 0 {
+		eta := time.Duration(float64(p.total-p.current)/rate) * time.Second
+		status += fmt.Sprintf(" ETA %v", eta.Round(time.Second))
+	}
+	return status
+}
+
+func (p *progress) amount(n float64) string {
+	if p.unit == ProgressFiles {
+		return fmt.Sprintf("%.0f", n)
+	}
+
+	units := []string{"B", "KB", "MB", "GB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%v", n, units[i])
+	}
+	return fmt.Sprintf("%.1f%v", n, units[i])
+}
+
+func truncateLabel(label string) string {
+	if len(label) > 30 {
+		return "..." + label[len(label)-27:]
+	}
+	return label
+}
+
+// progressWriter counts the bytes written through it as progress.
+type progressWriter struct {
+	w        io.Writer
+	progress ProgressReporter
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.progress.Add(int64(n))
+	return n, err
+}
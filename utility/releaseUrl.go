@@ -0,0 +1,43 @@
+package utility
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+)
+
+// releaseArchives are the archives a release may be published as, preferred first. Tarballs keep unix
+// permissions and symlinks so they win when a release has one.
+var releaseArchives = []string{"gocms.tar.zst", "gocms.tar.gz", config.BINARY_ARCHIVE}
+
+// ReleaseUrl is where the gocms release zip of version is downloaded from for this os.
+func ReleaseUrl(version string) string {
+	return releaseArchiveUrl(version, config.BINARY_ARCHIVE)
+}
+
+// FindReleaseUrl returns the url of the preferred archive published for version. Offline the cached archives
+// are looked at instead. When none is found the zip url is returned so the download reports what is missing.
+func FindReleaseUrl(version string) string {
+	var cache *DownloadCache
+	if offline {
+		cache, _ = OpenDownloadCache()
+	}
+	for _, archive := range releaseArchives {
+		archiveUrl := releaseArchiveUrl(version, archive)
+		switch {
+		case offline && cache != nil && cache.Lookup(archiveUrl, "") != nil:
+			return archiveUrl
+		case !offline && headUrl(archiveUrl) != nil:
+			return archiveUrl
+		}
+	}
+	return ReleaseUrl(version)
+}
+
+func releaseArchiveUrl(version string, archive string) string {
+	return fmt.Sprintf("%v://%v.%v/%v/%v/%v/%v", config.BINARY_PROTOCOL, config.BINARY_HOST, config.BINARY_DOMAIN, config.BINARY_DEFAULT_RELEASE, version, config.BINARY_OS_PATH, archive)
+}
+
+// GcmIndexUrl is where gcm self-update looks for newer builds of gcm.
+func GcmIndexUrl() string {
+	return fmt.Sprintf("%v://%v.%v/%v/%v", config.BINARY_PROTOCOL, config.BINARY_HOST, config.BINARY_DOMAIN, config.GCM_RELEASE, config.GCM_INDEX)
+}
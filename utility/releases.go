@@ -0,0 +1,288 @@
+package utility
+
+import (
+	"fmt"
+	"github.com/gocms-io/gcm/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// SharedReleasePaths live in shared/ and are linked into every release so they carry over between releases.
+// Themes are linked one by one instead, see linkSharedThemes.
+var SharedReleasePaths = []string{
+	config.ENV_FILE,
+	filepath.Join(config.CONTENT_DIR, config.PLUGINS_DIR),
+}
+
+var releaseNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// IsReleaseLayout reports whether siteDir keeps its releases side by side behind a current link.
+func IsReleaseLayout(siteDir string) bool {
+	info, err := os.Lstat(filepath.Join(siteDir, config.CURRENT_RELEASE_LINK))
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// ActiveInstallDir is the directory gocms runs from. That is the current link in the release layout and
+// siteDir itself otherwise.
+func ActiveInstallDir(siteDir string) string {
+	if IsReleaseLayout(siteDir) {
+		return filepath.Join(siteDir, config.CURRENT_RELEASE_LINK)
+	}
+	return siteDir
+}
+
+func ReleaseDir(siteDir string, name string) string {
+	return filepath.Join(siteDir, config.RELEASES_DIR, name)
+}
+
+// NewReleaseName names a release after when it was installed so names sort oldest first. A specific
+// version is added to make the name easier to recognise.
+func NewReleaseName(version string) string {
+	name := time.Now().UTC().Format("20060102150405")
+	if version != "" && version != config.BINARY_DEFAULT_VERSION {
+		name += "-" + releaseNameUnsafe.ReplaceAllString(version, "_")
+	}
+	return name
+}
+
+// ListReleases returns the names of the installed releases, oldest first.
+func ListReleases(siteDir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(siteDir, config.RELEASES_DIR))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CurrentRelease returns the name of the release the current link points at.
+func CurrentRelease(siteDir string) (string, error) {
+	target, err := os.Readlink(filepath.Join(siteDir, config.CURRENT_RELEASE_LINK))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(target), nil
+}
+
+// PreviousRelease returns the newest release older than the current one.
+func PreviousRelease(siteDir string) (string, error) {
+	current, err := CurrentRelease(siteDir)
+	if err != nil {
+		return "", err
+	}
+	names, err := ListReleases(siteDir)
+	if err != nil {
+		return "", err
+	}
+
+	previous := ""
+	for _, name := range names {
+		if name >= current {
+			break
+		}
+		previous = name
+	}
+	if previous == "" {
+		return "", fmt.Errorf("there is no release older than %v", current)
+	}
+	return previous, nil
+}
+
+// LinkSharedPaths replaces the shared paths of a release with links into shared/. A shared path that doesn't
+// exist yet is seeded with the release's own copy. Themes are shared too, except the default one.
+func LinkSharedPaths(siteDir string, releaseDir string) error {
+	for _, p := range SharedReleasePaths {
+		shared := filepath.Join(siteDir, config.SHARED_DIR, p)
+		inRelease := filepath.Join(releaseDir, p)
+
+		_, sharedErr := os.Lstat(shared)
+		_, releaseErr := os.Lstat(inRelease)
+
+		switch {
+		case os.IsNotExist(sharedErr) && os.IsNotExist(releaseErr):
+			// nothing to share
+			continue
+		case os.IsNotExist(sharedErr):
+			err := os.MkdirAll(filepath.Dir(shared), os.ModePerm)
+			if err != nil {
+				return err
+			}
+			err = os.Rename(inRelease, shared)
+			if err != nil {
+				return err
+			}
+		case sharedErr != nil:
+			return sharedErr
+		default:
+			err := os.RemoveAll(inRelease)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := linkShared(shared, inRelease)
+		if err != nil {
+			return err
+		}
+	}
+	return linkSharedThemes(siteDir, releaseDir)
+}
+
+// linkSharedThemes links the user's themes in shared/ into the release. The default theme comes with gocms, so
+// every release keeps its own and rolling back brings back the one it shipped with. Themes added to the current
+// release, ex: with 'gcm developer theme', are copied to shared/ first so they carry over.
+func linkSharedThemes(siteDir string, releaseDir string) error {
+	sharedThemes := filepath.Join(siteDir, config.SHARED_DIR, config.CONTENT_DIR, config.THEMES_DIR)
+	releaseThemes := filepath.Join(releaseDir, config.CONTENT_DIR, config.THEMES_DIR)
+
+	err := os.MkdirAll(sharedThemes, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(releaseThemes, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if IsReleaseLayout(siteDir) {
+		currentThemes := filepath.Join(siteDir, config.CURRENT_RELEASE_LINK, config.CONTENT_DIR, config.THEMES_DIR)
+		err = adoptThemes(currentThemes, sharedThemes, func(src, dest string) error {
+			return Copy(src, dest, false, false)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err = adoptThemes(releaseThemes, sharedThemes, os.Rename)
+	if err != nil {
+		return err
+	}
+
+	themes, err := ioutil.ReadDir(sharedThemes)
+	if err != nil {
+		return err
+	}
+	for _, theme := range themes {
+		// older gcm versions kept the default theme in shared/ too. The release's own wins.
+		if theme.Name() == config.THEMES_DEFAULT_DIR {
+			continue
+		}
+		inRelease := filepath.Join(releaseThemes, theme.Name())
+		err = os.RemoveAll(inRelease)
+		if err != nil {
+			return err
+		}
+		err = linkShared(filepath.Join(sharedThemes, theme.Name()), inRelease)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adoptThemes moves the themes in themesDir that shared/ doesn't have yet into it with move. The default theme
+// and themes that already are links are left alone.
+func adoptThemes(themesDir string, sharedThemes string, move func(src, dest string) error) error {
+	themes, err := ioutil.ReadDir(themesDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, theme := range themes {
+		if theme.Name() == config.THEMES_DEFAULT_DIR || !theme.IsDir() {
+			continue
+		}
+		shared := filepath.Join(sharedThemes, theme.Name())
+		if _, err := os.Lstat(shared); err == nil {
+			continue
+		}
+		err = move(filepath.Join(themesDir, theme.Name()), shared)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkShared links inRelease to shared with a relative link so the site directory can be moved.
+func linkShared(shared string, inRelease string) error {
+	err := os.MkdirAll(filepath.Dir(inRelease), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Rel(filepath.Dir(inRelease), shared)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, inRelease)
+}
+
+// SwitchRelease points the current link at the named release. The new link is made next to the old one and
+// renamed over it, so anything following the link sees either release and never a mix of both.
+func SwitchRelease(siteDir string, name string) error {
+	if _, err := os.Stat(filepath.Join(ReleaseDir(siteDir, name), config.BINARY_FILE)); err != nil {
+		return fmt.Errorf("release %v doesn't contain %v", name, config.BINARY_FILE)
+	}
+
+	link := filepath.Join(siteDir, config.CURRENT_RELEASE_LINK)
+	tmpLink := link + ".tmp"
+	_ = os.Remove(tmpLink)
+
+	err := os.Symlink(filepath.Join(config.RELEASES_DIR, name), tmpLink)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpLink, link)
+	if err != nil {
+		_ = os.Remove(tmpLink)
+		return err
+	}
+	return nil
+}
+
+// PruneReleases removes all but the newest keep releases. The current release is always kept.
+func PruneReleases(siteDir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	names, err := ListReleases(siteDir)
+	if err != nil {
+		return nil, err
+	}
+	current, err := CurrentRelease(siteDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) <= keep {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, name := range names[:len(names)-keep] {
+		if name == current {
+			continue
+		}
+		err = os.RemoveAll(ReleaseDir(siteDir, name))
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,116 @@
+package utility
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a writer that appends to a file and rotates it once it grows past MaxSize bytes.
+// Rotated files are renamed to <file>.1, <file>.2 ... and only MaxBackups of them are kept.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rf.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	var rotateErr error
+	if rf.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.MaxSize {
+		rotateErr = rf.rotate()
+		if rf.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	// a file that couldn't be rotated keeps growing rather than losing output
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return nil
+	}
+	return rf.file.Close()
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate moves the file aside and opens a new one. When that fails the old file is reopened, so file is only
+// left nil when it can't be opened at all.
+func (rf *RotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err == nil {
+		err = rf.shift()
+	}
+
+	openErr := rf.open()
+	if err != nil {
+		return err
+	}
+	return openErr
+}
+
+// shift renames the file and its backups up by one and drops the oldest.
+func (rf *RotatingFile) shift() error {
+	_ = os.Remove(fmt.Sprintf("%v.%v", rf.Path, rf.MaxBackups))
+	for i := rf.MaxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%v.%v", rf.Path, i), fmt.Sprintf("%v.%v", rf.Path, i+1))
+	}
+	if rf.MaxBackups > 0 {
+		return os.Rename(rf.Path, fmt.Sprintf("%v.1", rf.Path))
+	}
+	return os.Remove(rf.Path)
+}
@@ -0,0 +1,84 @@
+package utility
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTestFile(t *testing.T, path string) string {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestRotatingFileRotates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcm-rotate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gocms.log")
+	rf, err := NewRotatingFile(path, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for _, line := range []string{"aaa\n", "bbb\n", "ccc\n", "ddd\n"} {
+		_, err = rf.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]string{path: "ddd\n", path + ".1": "ccc\n", path + ".2": "bbb\n"}
+	for file, body := range expected {
+		if got := readTestFile(t, file); got != body {
+			t.Errorf("%v holds %q, expected %q", filepath.Base(file), got, body)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 backups were kept")
+	}
+}
+
+func TestRotatingFileKeepsWritingWhenRotationFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcm-rotate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a non empty directory in the way of the first backup makes the rename fail
+	path := filepath.Join(dir, "gocms.log")
+	err = os.MkdirAll(filepath.Join(path+".1", "blocked"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf, err := NewRotatingFile(path, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	_, err = rf.Write([]byte("aaa\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := rf.Write([]byte("bbb\n"))
+	if err == nil {
+		t.Error("expected the failed rotation to be reported")
+	}
+	if n != 4 {
+		t.Errorf("wrote %v bytes, expected 4", n)
+	}
+	_, _ = rf.Write([]byte("ccc\n"))
+	if got := readTestFile(t, path); got != "aaa\nbbb\nccc\n" {
+		t.Errorf("log holds %q after a failed rotation", got)
+	}
+}
@@ -0,0 +1,72 @@
+package utility
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/flynn-archive/go-shlex"
+	"github.com/gocms-io/gcm/models"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// RunHook runs a single hook command. Relative hook directories are resolved against baseDir.
+// The hook is killed if ctx is cancelled or its timeout passes. Its output goes to stdout and stderr.
+func RunHook(ctx context.Context, hook *models.PluginHook, baseDir string, verbose bool, stdout io.Writer, stderr io.Writer) error {
+
+	// split command into args
+	args, err := shlex.Split(hook.Command)
+	if err != nil {
+		return fmt.Errorf("can't parse command '%v': %v", hook.Command, err.Error())
+	}
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+
+	// apply timeout if one is set
+	if hook.Timeout != "" {
+		timeout, err := time.ParseDuration(hook.Timeout)
+		if err != nil {
+			return fmt.Errorf("can't parse timeout '%v': %v", hook.Timeout, err.Error())
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+
+	// working dir
+	cmd.Dir = baseDir
+	if hook.Dir != "" {
+		cmd.Dir = hook.Dir
+		if !filepath.IsAbs(hook.Dir) {
+			cmd.Dir = filepath.Join(baseDir, hook.Dir)
+		}
+	}
+
+	// env
+	cmd.Env = os.Environ()
+	for key, value := range hook.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", key, value))
+	}
+
+	if verbose {
+		fmt.Printf("Running '%v' in %v\n", hook.Command, cmd.Dir)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %v", hook.Timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package utility
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const sitesFileEnv = "GCM_SITES_FILE"
+
+var siteNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Site is a named gocms installation so commands can be pointed at it with --site.
+type Site struct {
+	Name      string    `json:"name"`
+	Directory string    `json:"directory"`
+	Added     time.Time `json:"added"`
+}
+
+// SiteRegistry is the list of named installations kept for the user.
+type SiteRegistry struct {
+	File  string
+	Sites []*Site
+}
+
+// SitesFile is where the registry is kept. GCM_SITES_FILE overrides the user config dir.
+func SitesFile() (string, error) {
+	if file := os.Getenv(sitesFileEnv); file != "" {
+		return file, nil
+	}
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userConfigDir, "gcm", "sites.json"), nil
+}
+
+func OpenSiteRegistry() (*SiteRegistry, error) {
+	file, err := SitesFile()
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &SiteRegistry{File: file}
+	raw, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// unlike the download cache this can't be rebuilt, so a broken registry is an error
+	err = json.Unmarshal(raw, &registry.Sites)
+	if err != nil {
+		return nil, fmt.Errorf("can't read %v: %v", file, err.Error())
+	}
+	return registry, nil
+}
+
+// Save writes the registry through a temp file so a crash never leaves half a registry behind.
+func (r *SiteRegistry) Save() error {
+	sort.Slice(r.Sites, func(i, j int) bool {
+		return r.Sites[i].Name < r.Sites[j].Name
+	})
+	raw, err := json.MarshalIndent(r.Sites, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(r.File), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	tmpFile := r.File + ".tmp"
+	err = ioutil.WriteFile(tmpFile, raw, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, r.File)
+}
+
+func (r *SiteRegistry) Lookup(name string) *Site {
+	for _, site := range r.Sites {
+		if site.Name == name {
+			return site
+		}
+	}
+	return nil
+}
+
+// Add registers directory under name. The directory is stored as an absolute path so the site can be used
+// from anywhere.
+func (r *SiteRegistry) Add(name string, directory string) (*Site, error) {
+	if !siteNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("'%v' isn't a valid site name. Use letters, digits, '.', '_' and '-'", name)
+	}
+	if r.Lookup(name) != nil {
+		return nil, fmt.Errorf("a site named %v already exists", name)
+	}
+
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	site := &Site{
+		Name:      name,
+		Directory: directory,
+		Added:     time.Now(),
+	}
+	r.Sites = append(r.Sites, site)
+	return site, nil
+}
+
+// Remove drops the site named name. It returns nil when there is no such site.
+func (r *SiteRegistry) Remove(name string) *Site {
+	for i, site := range r.Sites {
+		if site.Name == name {
+			r.Sites = append(r.Sites[:i], r.Sites[i+1:]...)
+			return site
+		}
+	}
+	return nil
+}
@@ -1,77 +1,62 @@
 package utility
 
 import (
-	"bufio"
 	"fmt"
 	"github.com/gocms-io/gcm/config/config_os"
 	"github.com/gocms-io/gcm/utility/utility_os"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 )
 
-// start goCMS
-func StartGoCMS(destDir string, goCMSDevMode bool, doneChan chan bool) {
+type GoCMSOptions struct {
+	// DevMode builds gocms from source before running it
+	DevMode bool
+	// Env holds KEY=VALUE pairs that override the inherited environment and .env
+	Env []string
+	// Stdout and Stderr default to the ones of gcm
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewGoCMSSupervisor returns a supervisor for the gocms binary in destDir. In the release layout gocms runs
+// from the current link, which is followed on every start so a restart picks up a newly switched release.
+func NewGoCMSSupervisor(destDir string, options GoCMSOptions) (*Supervisor, error) {
+	destDir = ActiveInstallDir(destDir)
 
 	// if dev mode first build gocms
-	if goCMSDevMode {
+	if options.DevMode {
 		// guild gocms first
 		goCMSBuildCMD := exec.Command("go", "build", "-o", config_os.BINARY_FILE, "main.go")
 		goCMSBuildCMD.Dir = destDir
 		out, err := goCMSBuildCMD.CombinedOutput()
 		fmt.Printf("GOCMS Build Output: %v\n ", string(out))
 		if err != nil {
-			return
+			return nil, err
 		}
-
-	}
-
-	// build command
-	var cmd *exec.Cmd
-	commandString := filepath.FromSlash("./" + config_os.BINARY_FILE)
-	cmd = exec.Command(commandString)
-	cmd.Dir = destDir
-	// set process group
-	utility_os.SetChildProcessGroup(cmd)
-
-	// set stdout to pipe
-	cmdStdoutReader, err := cmd.StdoutPipe()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error creating StdoutPipe for Cmd", err)
-		os.Exit(0)
 	}
 
-	// setup stdout to scan continuously
-	stdOutScanner := bufio.NewScanner(cmdStdoutReader)
-	go func() {
-		for stdOutScanner.Scan() {
-			fmt.Printf("%s\n", stdOutScanner.Text())
+	supervisor := NewSupervisor("GoCMS", func() (*exec.Cmd, error) {
+		// build command
+		commandString := filepath.FromSlash("./" + config_os.BINARY_FILE)
+		cmd := exec.Command(commandString)
+		cmd.Dir = destDir
+		// set process group
+		utility_os.SetChildProcessGroup(cmd)
+
+		cmd.Env = append(os.Environ(), options.Env...)
+		cmd.Stdout = os.Stdout
+		if options.Stdout != nil {
+			cmd.Stdout = options.Stdout
 		}
-	}()
-
-	// set stderr to pipe
-	cmdStderrReader, err := cmd.StderrPipe()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error creating StdoutPipe for Cmd", err)
-		os.Exit(0)
-	}
-
-	// setup stderr to scan continuously
-	stdErrScanner := bufio.NewScanner(cmdStderrReader)
-	go func() {
-		for stdErrScanner.Scan() {
-			fmt.Printf("%s\n", stdErrScanner.Text())
+		cmd.Stderr = os.Stderr
+		if options.Stderr != nil {
+			cmd.Stderr = options.Stderr
 		}
-	}()
 
-	err = cmd.Start()
-	if err != nil {
-		fmt.Printf("Error starting gocms: %v\n", err.Error())
-		os.Exit(0)
-	}
+		return cmd, nil
+	})
 
-	select {
-	case <-doneChan:
-		utility_os.Kill_process(cmd)
-	}
+	return supervisor, nil
 }
@@ -0,0 +1,384 @@
+package utility
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocms-io/gcm/utility/utility_os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+type ProcessState int
+
+const (
+	ProcessStarting ProcessState = iota
+	ProcessRunning
+	ProcessExited
+	ProcessCrashed
+	ProcessRestarting
+	ProcessStopping
+	ProcessStopped
+	ProcessFailed
+)
+
+func (s ProcessState) String() string {
+	switch s {
+	case ProcessStarting:
+		return "starting"
+	case ProcessRunning:
+		return "running"
+	case ProcessExited:
+		return "exited"
+	case ProcessCrashed:
+		return "crashed"
+	case ProcessRestarting:
+		return "restarting"
+	case ProcessStopping:
+		return "stopping"
+	case ProcessStopped:
+		return "stopped"
+	case ProcessFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "no"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+func ParseRestartPolicy(policy string) (RestartPolicy, error) {
+	switch RestartPolicy(policy) {
+	case "", RestartNever:
+		return RestartNever, nil
+	case RestartOnFailure, RestartAlways:
+		return RestartPolicy(policy), nil
+	}
+	return RestartNever, fmt.Errorf("unknown restart policy '%v'. Use no, on-failure or always", policy)
+}
+
+// ProcessEvent describes a change in the state of a supervised process.
+// ExitCode is only meaningful for the exited and crashed states and is -1 when the process was killed by a signal.
+type ProcessEvent struct {
+	Name     string
+	State    ProcessState
+	Pid      int
+	ExitCode int
+	Err      error
+	Restarts int
+	Uptime   time.Duration
+	Time     time.Time
+}
+
+var ErrSupervisorStarted = errors.New("process is already supervised")
+
+// Supervisor runs a child process, reaps it when it exits and restarts it according to its restart policy
+// with an exponential backoff. Stop asks the process to shut down and kills it if it hasn't exited within
+// ShutdownTimeout. Every state change is sent on Events without ever blocking the supervisor.
+type Supervisor struct {
+	Name            string
+	NewCmd          func() (*exec.Cmd, error)
+	Restart         RestartPolicy
+	MaxRestarts     int
+	MinBackoff      time.Duration
+	MaxBackoff      time.Duration
+	ShutdownTimeout time.Duration
+	Events          chan ProcessEvent
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	state    ProcessState
+	restarts int
+	started  bool
+	stopping bool
+	stopChan chan struct{}
+	exitChan chan struct{}
+	doneChan chan struct{}
+}
+
+// NewSupervisor returns a supervisor that doesn't restart and gives the process 10 seconds to shut down.
+// newCmd is called for every start so each run gets a fresh exec.Cmd.
+func NewSupervisor(name string, newCmd func() (*exec.Cmd, error)) *Supervisor {
+	return &Supervisor{
+		Name:            name,
+		NewCmd:          newCmd,
+		Restart:         RestartNever,
+		MinBackoff:      time.Second,
+		MaxBackoff:      30 * time.Second,
+		ShutdownTimeout: 10 * time.Second,
+		Events:          make(chan ProcessEvent, 64),
+		stopChan:        make(chan struct{}),
+		doneChan:        make(chan struct{}),
+	}
+}
+
+// Start starts the process. Errors starting it the first time are returned rather than retried.
+func (s *Supervisor) Start() error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return ErrSupervisorStarted
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	err := s.startProcess()
+	if err != nil {
+		s.setState(ProcessFailed, ProcessEvent{Err: err})
+		close(s.doneChan)
+		return err
+	}
+
+	go s.monitor()
+	return nil
+}
+
+// Stop shuts the process down and waits until it has been reaped. It doesn't restart afterwards.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.started = true
+		s.stopping = true
+		close(s.doneChan)
+		s.mu.Unlock()
+		return
+	}
+	if s.stopping {
+		s.mu.Unlock()
+		<-s.doneChan
+		return
+	}
+	s.stopping = true
+	close(s.stopChan)
+	cmd := s.cmd
+	exitChan := s.exitChan
+	running := s.state == ProcessRunning
+	s.mu.Unlock()
+
+	if running {
+		s.setState(ProcessStopping, ProcessEvent{Pid: cmd.Process.Pid})
+		utility_os.Kill_process(cmd)
+
+		select {
+		case <-exitChan:
+		case <-time.After(s.ShutdownTimeout):
+			fmt.Printf("%v didn't stop within %v. Killing it.\n", s.Name, s.ShutdownTimeout)
+			utility_os.Force_kill_process(cmd)
+		}
+	}
+
+	<-s.doneChan
+}
+
+// Done is closed once the process has exited for good.
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.doneChan
+}
+
+func (s *Supervisor) State() ProcessState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Pid returns the pid of the current process or 0 when there isn't one.
+func (s *Supervisor) Pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil || s.cmd.Process == nil || s.state != ProcessRunning {
+		return 0
+	}
+	return s.cmd.Process.Pid
+}
+
+func (s *Supervisor) startProcess() error {
+	s.setState(ProcessStarting, ProcessEvent{})
+
+	cmd, err := s.NewCmd()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cmd = cmd
+	s.exitChan = make(chan struct{})
+	stopping := s.stopping
+	s.mu.Unlock()
+
+	// stopped while starting so don't leave it running
+	if stopping {
+		utility_os.Kill_process(cmd)
+	}
+
+	s.setState(ProcessRunning, ProcessEvent{Pid: cmd.Process.Pid})
+	return nil
+}
+
+func (s *Supervisor) monitor() {
+	defer close(s.doneChan)
+
+	backoff := s.MinBackoff
+	for {
+		s.mu.Lock()
+		cmd := s.cmd
+		exitChan := s.exitChan
+		s.mu.Unlock()
+
+		// reap the process
+		startTime := time.Now()
+		err := cmd.Wait()
+		uptime := time.Since(startTime)
+		close(exitChan)
+
+		exitCode := cmd.ProcessState.ExitCode()
+		event := ProcessEvent{Pid: cmd.Process.Pid, ExitCode: exitCode, Err: err, Uptime: uptime}
+
+		s.mu.Lock()
+		stopping := s.stopping
+		s.mu.Unlock()
+		if stopping {
+			s.setState(ProcessStopped, event)
+			return
+		}
+
+		crashed := err != nil
+		if crashed {
+			s.setState(ProcessCrashed, event)
+		} else {
+			s.setState(ProcessExited, event)
+		}
+
+		// restart as long as the policy allows it
+		for {
+			if !s.shouldRestart(crashed) {
+				if crashed {
+					s.setState(ProcessFailed, event)
+				} else {
+					s.setState(ProcessStopped, event)
+				}
+				return
+			}
+
+			// a process that stayed up for a while earns a fresh backoff
+			if uptime > s.MaxBackoff {
+				backoff = s.MinBackoff
+			}
+
+			s.mu.Lock()
+			s.restarts++
+			s.mu.Unlock()
+			s.setState(ProcessRestarting, ProcessEvent{ExitCode: exitCode})
+
+			select {
+			case <-time.After(backoff):
+			case <-s.stopChan:
+				s.setState(ProcessStopped, event)
+				return
+			}
+			backoff *= 2
+			if backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+
+			err = s.startProcess()
+			if err == nil {
+				break
+			}
+			event = ProcessEvent{Err: err}
+			s.setState(ProcessFailed, event)
+			crashed = true
+			uptime = 0
+		}
+	}
+}
+
+func (s *Supervisor) shouldRestart(crashed bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return false
+	}
+	if s.MaxRestarts > 0 && s.restarts >= s.MaxRestarts {
+		return false
+	}
+
+	switch s.Restart {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return crashed
+	}
+	return false
+}
+
+func (s *Supervisor) setState(state ProcessState, event ProcessEvent) {
+	s.mu.Lock()
+	s.state = state
+	event.Name = s.Name
+	event.State = state
+	event.Restarts = s.restarts
+	event.Time = time.Now()
+	s.mu.Unlock()
+
+	// never block on a slow or missing reader
+	select {
+	case s.Events <- event:
+	default:
+	}
+}
+
+// PrintProcessEvents reports state changes of a supervised process until it is done.
+func PrintProcessEvents(s *Supervisor) {
+	for {
+		select {
+		case event := <-s.Events:
+			printProcessEvent(event)
+		case <-s.Done():
+			// report whatever is left
+			for {
+				select {
+				case event := <-s.Events:
+					printProcessEvent(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func printProcessEvent(event ProcessEvent) {
+	switch event.State {
+	case ProcessRunning:
+		fmt.Printf("%v running (pid %v)\n", event.Name, event.Pid)
+	case ProcessExited:
+		fmt.Printf("%v exited with code %v after %v\n", event.Name, event.ExitCode, event.Uptime.Round(time.Millisecond))
+	case ProcessCrashed:
+		if event.Uptime < 5*time.Second {
+			fmt.Printf("%v crashed on startup with code %v: %v\n", event.Name, event.ExitCode, event.Err)
+		} else {
+			fmt.Printf("%v crashed with code %v after %v: %v\n", event.Name, event.ExitCode, event.Uptime.Round(time.Millisecond), event.Err)
+		}
+	case ProcessRestarting:
+		fmt.Printf("Restarting %v (restart %v)\n", event.Name, event.Restarts)
+	case ProcessFailed:
+		if event.Err != nil {
+			fmt.Printf("%v failed: %v\n", event.Name, event.Err)
+		} else {
+			fmt.Printf("%v failed\n", event.Name)
+		}
+	case ProcessStopped:
+		fmt.Printf("%v stopped\n", event.Name)
+	}
+}
@@ -0,0 +1,156 @@
+package utility
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// untarWithLimits extracts a tar, tar.gz or tar.zst archive. Progress is measured on the archive as it is read
+// since a compressed tar doesn't say up front how much it holds.
+func untarWithLimits(src, dest string, format ArchiveFormat, limits ExtractLimits) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return NewError(KindUnpack, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return NewError(KindUnpack, err)
+	}
+	progress := NewProgress("Unpacking "+filepath.Base(src), ProgressBytes)
+	progress.SetTotal(info.Size())
+	defer progress.Done()
+
+	var r io.Reader = &progressReader{r: f, progress: progress}
+	switch format {
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return Errorf(KindUnpack, "can't read %v: %v", src, err.Error())
+		}
+		defer gz.Close()
+		r = gz
+	case ArchiveTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return Errorf(KindUnpack, "can't read %v: %v", src, err.Error())
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	e, err := newExtractor(dest, limits, nil)
+	if err != nil {
+		return NewError(KindUnpack, err)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = untarEntry(e, tr, hdr)
+		}
+		if err != nil {
+			_ = e.checkLinks()
+			return Errorf(KindUnpack, "can't extract %v: %v", src, err.Error())
+		}
+	}
+
+	return NewError(KindUnpack, e.finish())
+}
+
+func untarEntry(e *extractor, tr *tar.Reader, hdr *tar.Header) error {
+	mode := os.FileMode(hdr.Mode).Perm()
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return e.dir(hdr.Name, mode, hdr.ModTime)
+	case tar.TypeReg, tar.TypeRegA:
+		return e.file(hdr.Name, tr, mode, hdr.ModTime)
+	case tar.TypeSymlink:
+		return e.symlink(hdr.Name, hdr.Linkname)
+	case tar.TypeLink:
+		return e.link(hdr.Name, hdr.Linkname)
+	case tar.TypeXGlobalHeader:
+		return nil
+	}
+	return fmt.Errorf("archive entry %v isn't a regular file, directory or link", hdr.Name)
+}
+
+// writeTar packs srcDir as a tar, compressed according to format. Owners are left out since they won't mean
+// anything where the archive is extracted.
+func writeTar(w io.Writer, srcDir string, format ArchiveFormat) error {
+	var compressor io.WriteCloser
+	switch format {
+	case ArchiveTarGz:
+		compressor = gzip.NewWriter(w)
+	case ArchiveTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		compressor = zw
+	}
+	if compressor != nil {
+		w = compressor
+	}
+
+	tw := tar.NewWriter(w)
+	err := archiveWalk(srcDir, func(name string, path string, info os.FileInfo) error {
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			var err error
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	if compressor != nil {
+		return compressor.Close()
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package utility_os
 
 import (
 	"os/exec"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -11,4 +13,43 @@ func SetChildProcessGroup(cmd *exec.Cmd) {
 
 func Kill_process(cmd *exec.Cmd) {
 	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
-}
\ No newline at end of file
+}
+
+func Force_kill_process(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+func Terminate_pid(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+func Restart_pid(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
+
+// Pid_exists reports whether pid is alive. EPERM means it is, but belongs to another user.
+func Pid_exists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// Find_pids_by_exe returns the processes running the executable at exe according to ps.
+func Find_pids_by_exe(exe string) []int {
+	out, err := exec.Command("ps", "-axo", "pid=,comm=").Output()
+	if err != nil {
+		return nil
+	}
+
+	var pids []int
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[1]) != exe {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
@@ -1,7 +1,12 @@
 package utility_os
 
 import (
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -11,4 +16,47 @@ func SetChildProcessGroup(cmd *exec.Cmd) {
 
 func Kill_process(cmd *exec.Cmd) {
 	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
-}
\ No newline at end of file
+}
+
+func Force_kill_process(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+func Terminate_pid(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+func Restart_pid(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
+
+// Pid_exists reports whether pid is alive. EPERM means it is, but belongs to another user.
+func Pid_exists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// Find_pids_by_exe returns the processes running the executable at exe. Only processes whose
+// executable can be read from /proc, usually those of the same user, are found.
+func Find_pids_by_exe(exe string) []int {
+	dirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var pids []int
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+		path, err := os.Readlink(filepath.Join("/proc", dir.Name(), "exe"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSuffix(path, " (deleted)") == exe {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
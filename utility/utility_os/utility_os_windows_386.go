@@ -1,14 +1,67 @@
 package utility_os
 
 import (
+	"errors"
 	"os/exec"
+	"strconv"
 	"syscall"
 )
 
+// processQueryLimitedInformation is the least access OpenProcess can ask for. syscall doesn't define it.
+const processQueryLimitedInformation = 0x1000
+
+// stillActive is the exit code GetExitCodeProcess reports for a process that hasn't exited.
+const stillActive = 259
+
 func SetChildProcessGroup(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
 }
 
 func Kill_process(cmd *exec.Cmd) {
-	cmd.Process.Kill()
-}
\ No newline at end of file
+	if kill_tree(cmd.Process.Pid) != nil {
+		cmd.Process.Kill()
+	}
+}
+
+func Force_kill_process(cmd *exec.Cmd) {
+	if kill_tree(cmd.Process.Pid) != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// Terminate_pid kills pid along with the processes it started. Windows has no signal a console process can
+// catch to stop cleanly, and killing gcm alone would leave the gocms it supervises running.
+func Terminate_pid(pid int) error {
+	return kill_tree(pid)
+}
+
+func kill_tree(pid int) error {
+	out, err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).CombinedOutput()
+	if err != nil {
+		return errors.New("taskkill failed: " + string(out))
+	}
+	return nil
+}
+
+func Restart_pid(pid int) error {
+	return errors.New("restarting a running gcm isn't supported on windows. Stop it and run it again")
+}
+
+// Pid_exists reports whether pid is alive. A process that exited but is still held open by someone counts as gone.
+func Pid_exists(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// processes of other users can't always be opened but are there
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	err = syscall.GetExitCodeProcess(h, &code)
+	return err != nil || code == stillActive
+}
+
+// Find_pids_by_exe isn't supported on windows. Only gocms started with 'gcm run' is found, through its pid file.
+func Find_pids_by_exe(exe string) []int {
+	return nil
+}
@@ -2,66 +2,136 @@ package utility
 
 import (
 	"archive/zip"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 )
 
+// Unzip extracts src into dest with the default limits.
 func Unzip(src, dest string) error {
+	return UnzipWithLimits(src, dest, DefaultExtractLimits)
+}
+
+// UnzipWithLimits extracts src into dest. Entries that would land outside dest, symlinks pointing outside it
+// and archives that go over limits are refused. Modes and modification times are kept.
+func UnzipWithLimits(src, dest string, limits ExtractLimits) (err error) {
 	r, err := zip.OpenReader(src)
 	if err != nil {
-		return err
+		return NewError(KindUnpack, err)
 	}
 	defer func() {
-		if err := r.Close(); err != nil {
-			panic(err)
+		closeErr := r.Close()
+		if err == nil && closeErr != nil {
+			err = NewError(KindUnpack, closeErr)
 		}
 	}()
 
-	os.MkdirAll(dest, 0755)
+	// reject what the headers already give away before writing anything
+	if limits.MaxFiles > 0 && len(r.File) > limits.MaxFiles {
+		return Errorf(KindUnpack, "%v has %v entries, more than the limit of %v", src, len(r.File), limits.MaxFiles)
+	}
+	var total int64
+	for _, f := range r.File {
+		total += int64(f.UncompressedSize64)
+	}
+	if limits.MaxTotalSize > 0 && total > limits.MaxTotalSize {
+		return Errorf(KindUnpack, "%v expands to %v bytes, more than the limit of %v", src, total, limits.MaxTotalSize)
+	}
 
-	// Closure to address file descriptors issue with all the deferred .Close() methods
-	extractAndWriteFile := func(f *zip.File) error {
-		rc, err := f.Open()
+	progress := NewProgress("Unpacking "+filepath.Base(src), ProgressBytes)
+	progress.SetTotal(total)
+	defer progress.Done()
+
+	e, err := newExtractor(dest, limits, progress)
+	if err != nil {
+		return NewError(KindUnpack, err)
+	}
+
+	for _, f := range r.File {
+		err = unzipFile(e, f)
+		if err != nil {
+			_ = e.checkLinks()
+			return Errorf(KindUnpack, "can't extract %v: %v", src, err.Error())
+		}
+	}
+
+	return NewError(KindUnpack, e.finish())
+}
+
+func unzipFile(e *extractor, f *zip.File) error {
+	mode := f.Mode()
+	modTime := f.Modified
+	if modTime.IsZero() {
+		modTime = f.ModTime()
+	}
+
+	if mode.IsDir() {
+		return e.dir(f.Name, mode, modTime)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	switch {
+	case mode&os.ModeSymlink != 0:
+		// a zip symlink keeps its target as the file contents
+		target, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
 		if err != nil {
 			return err
 		}
-		defer func() {
-			if err := rc.Close(); err != nil {
-				panic(err)
-			}
-		}()
+		return e.symlink(f.Name, string(target))
+	case mode.IsRegular():
+		return e.file(f.Name, rc, mode, modTime)
+	}
+	return fmt.Errorf("archive entry %v isn't a regular file, directory or symlink", f.Name)
+}
+
+// writeZip packs srcDir as a zip. Symlinks are stored the way zip tools expect, as entries holding their target.
+func writeZip(w io.Writer, srcDir string) error {
+	zw := zip.NewWriter(w)
+	err := archiveWalk(srcDir, func(name string, path string, info os.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		} else if info.Mode().IsRegular() {
+			hdr.Method = zip.Deflate
+		}
 
-		path := filepath.Join(dest, f.Name)
+		entry, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
 
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(path, f.Mode())
-		} else {
-			os.MkdirAll(filepath.Dir(path), f.Mode())
-			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
 			if err != nil {
 				return err
 			}
-			defer func() {
-				if err := f.Close(); err != nil {
-					panic(err)
-				}
-			}()
-
-			_, err = io.Copy(f, rc)
+			_, err = io.WriteString(entry, filepath.ToSlash(target))
+			return err
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
 			if err != nil {
 				return err
 			}
-		}
-		return nil
-	}
-
-	for _, f := range r.File {
-		err := extractAndWriteFile(f)
-		if err != nil {
+			defer f.Close()
+			_, err = io.Copy(entry, f)
 			return err
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return zw.Close()
 }